@@ -0,0 +1,159 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Agent names, used as keys in configuration and saved metadata.
+const (
+	Analyst    = "analyst"
+	Researcher = "researcher"
+	Architect  = "architect"
+	Judge      = "judge"
+)
+
+// Names lists every agent in pipeline order.
+var Names = []string{Analyst, Researcher, Architect, Judge}
+
+// Settings controls which model an agent runs on and how it samples.
+type Settings struct {
+	Model string `json:"model"`
+	Options
+}
+
+// String renders the settings on one line, e.g. "gpt-4o t=0.2 max=2000".
+func (s Settings) String() string {
+	out := s.Model
+	if s.Temperature != nil {
+		out += fmt.Sprintf(" t=%.2g", *s.Temperature)
+	}
+	if s.MaxTokens > 0 {
+		out += fmt.Sprintf(" max=%d", s.MaxTokens)
+	}
+	if s.ReasoningEffort != "" {
+		out += " effort=" + s.ReasoningEffort
+	}
+	return out
+}
+
+// Agent binds a Provider to the Settings of one agent.
+type Agent struct {
+	Name     string
+	Provider Provider
+	Settings Settings
+
+	// Prompts are the system prompt templates; nil means DefaultPrompts.
+	Prompts *Prompts
+
+	// Timeout bounds a single completion call. Zero means no limit beyond ctx.
+	Timeout time.Duration
+
+	// Retry decides how transient failures (rate limits, 5xx, dropped
+	// connections) are retried. OnRetry, if set, is told before each wait.
+	Retry   RetryPolicy
+	OnRetry func(RetryEvent)
+
+	// MaxRepairs bounds how often invalid JSON output is sent back for repair.
+	MaxRepairs int
+
+	// Notify, if set, receives progress notes meant for the user.
+	Notify func(string)
+
+	// OnUsage, if set, receives the token usage of every completion the
+	// agent makes, including repairs and tool steps. It may be called
+	// concurrently.
+	OnUsage func(model string, u Usage)
+
+	// Progress, if set, turns on streaming and receives the partial reply
+	// accumulated so far. It starts over from "" on every repair attempt.
+	Progress func(partial string)
+}
+
+// notify forwards a progress note to Notify, if any.
+func (a Agent) notify(note string) {
+	if a.Notify != nil {
+		a.Notify(note)
+	}
+}
+
+// prompt renders the agent's system prompt template name.
+func (a Agent) prompt(name string, data PromptData) (string, error) {
+	p := a.Prompts
+	if p == nil {
+		p = DefaultPrompts
+	}
+	data.Agent = a.Name
+	return p.Render(name, data)
+}
+
+// complete sends messages to the agent's provider using its settings.
+// schema may be nil for free-form replies.
+func (a Agent) complete(ctx context.Context, messages []Message, schema *JSONSchema) (Response, error) {
+	req := Request{Messages: messages, Schema: schema}
+	var partial strings.Builder
+	if a.Progress != nil {
+		req.Stream = func(delta string) {
+			partial.WriteString(delta)
+			a.Progress(partial.String())
+		}
+	}
+	return a.send(ctx, req, func() {
+		if a.Progress != nil {
+			partial.Reset()
+			a.Progress("")
+		}
+	})
+}
+
+// completeTools is like complete but offers tools to the model. Replies
+// are not streamed since they are mostly tool calls.
+func (a Agent) completeTools(ctx context.Context, messages []Message, tools []Tool) (Response, error) {
+	return a.send(ctx, Request{Messages: messages, Tools: tools}, nil)
+}
+
+// send fills in the agent's model and options and retries transient
+// failures according to its RetryPolicy. restart, if set, runs before
+// every attempt so streamed output can start over.
+func (a Agent) send(ctx context.Context, req Request, restart func()) (Response, error) {
+	req.Model = a.Settings.Model
+	if req.Model == "" {
+		req.Model = DefaultModel
+	}
+	req.Options = a.Settings.Options
+
+	for attempt := 1; ; attempt++ {
+		if restart != nil {
+			restart()
+		}
+		res, err := a.attempt(ctx, req)
+		if err == nil {
+			if a.OnUsage != nil {
+				a.OnUsage(req.Model, res.Usage)
+			}
+			return res, nil
+		}
+		if attempt >= a.Retry.MaxAttempts || ctx.Err() != nil || !Retryable(err) {
+			return Response{}, err
+		}
+		wait := a.Retry.Delay(attempt, err)
+		if a.OnRetry != nil {
+			a.OnRetry(RetryEvent{Attempt: attempt, MaxAttempts: a.Retry.MaxAttempts, Wait: wait, Err: err})
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return Response{}, err
+		}
+	}
+}
+
+// attempt makes a single call, bounded by the agent's Timeout.
+func (a Agent) attempt(ctx context.Context, req Request) (Response, error) {
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+	return a.Provider.Complete(ctx, req)
+}
@@ -4,53 +4,118 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-
-	"github.com/openai/openai-go/v3"
+	"strings"
 )
 
+// MaxAnalystRounds is how many rounds of questions the Analyst may ask
+// before it has to decide with what it knows.
+const MaxAnalystRounds = 4
+
 type AnalystResponse struct {
-	Status    string   `json:"status"` // "valid", "needs_info", "invalid"
-	Reason    string   `json:"reason"`
-	Questions []string `json:"questions"`
-	Summary   string   `json:"summary"` // Refined understanding of the request
+	Status       string       `json:"status" enum:"valid,needs_info,invalid"`
+	Reason       string       `json:"reason"`
+	Questions    []string     `json:"questions"`
+	Requirements Requirements `json:"requirements"` // Refined understanding of the request
 }
 
-// AnalyzeRequest uses the OpenAI API to evaluate the user's input.
-func AnalyzeRequest(client *openai.Client, input string, history []string) (AnalystResponse, error) {
-	// Construct the prompt
-	sysPrompt := `You are an expert Requirements Analyst for a Flowchart Builder.
-Your job is to analyze the user's request and determine if it's sufficient to build a flowchart.
-
-Return a JSON object with:
-- "status": "valid" (ready to build), "needs_info" (ambiguous/incomplete), or "invalid" (nonsense/unrelated).
-- "reason": A short explanation of your decision.
-- "questions": A list of 1-3 specific questions if status is "needs_info". Empty otherwise.
-- "summary": A professional summary of the requirements so far.
-
-Example:
-Input: "Order flow"
-Response: {"status": "needs_info", "reason": "Too vague", "questions": ["What triggers the order?", "Are there approval steps?"], "summary": "User wants an order process."}
-`
-
-	// Build messages including history if needed (simplified for now)
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(sysPrompt),
-		openai.UserMessage(fmt.Sprintf("User Input: %s", input)),
+// Requirements is the Analyst's structured understanding of the request.
+// Downstream agents receive it rendered with String.
+type Requirements struct {
+	Summary     string   `json:"summary"`
+	Actors      []string `json:"actors"`
+	Triggers    []string `json:"triggers"`
+	HappyPath   []string `json:"happy_path"`
+	ErrorCases  []string `json:"error_cases"`
+	Constraints []string `json:"constraints"`
+}
+
+// String renders the requirements as a Markdown document.
+func (r Requirements) String() string {
+	var sb strings.Builder
+	sb.WriteString("## Summary\n" + r.Summary + "\n")
+	list := func(title string, items []string, numbered bool) {
+		if len(items) == 0 {
+			return
+		}
+		sb.WriteString("\n## " + title + "\n")
+		for i, item := range items {
+			if numbered {
+				fmt.Fprintf(&sb, "%d. %s\n", i+1, item)
+			} else {
+				sb.WriteString("- " + item + "\n")
+			}
+		}
 	}
+	list("Actors", r.Actors, false)
+	list("Triggers", r.Triggers, false)
+	list("Happy Path", r.HappyPath, true)
+	list("Error Cases", r.ErrorCases, false)
+	list("Constraints", r.Constraints, false)
+	return sb.String()
+}
 
-	// Make the call
-	res, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: messages,
-		Model:    openai.ChatModelGPT5Nano2025_08_07,
-	})
+// AnalystRound is one round of questions and the user's answers to them.
+type AnalystRound struct {
+	Response AnalystResponse `json:"response"`
+	Answers  []string        `json:"answers"`
+}
+
+var analystSchema = SchemaFor("analyst_response", AnalystResponse{})
+
+// AnalyzeRequest asks the LLM to evaluate the user's input. Earlier rounds
+// are replayed as the conversation they were, so the Analyst sees its own
+// questions and the answers it got.
+func AnalyzeRequest(ctx context.Context, a Agent, input string, rounds []AnalystRound) (AnalystResponse, error) {
+	// Construct the prompt
+	sysPrompt, err := a.prompt(PromptAnalyst, PromptData{MaxRounds: MaxAnalystRounds})
 	if err != nil {
 		return AnalystResponse{}, err
 	}
 
-	content := res.Choices[0].Message.Content
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(fmt.Sprintf("User Input: %s", input)),
+	}
+	for _, r := range rounds {
+		reply, _ := json.Marshal(r.Response)
+		var answers strings.Builder
+		for i, q := range r.Response.Questions {
+			answer := "(no answer)"
+			if i < len(r.Answers) {
+				answer = r.Answers[i]
+			}
+			fmt.Fprintf(&answers, "Q: %s\nA: %s\n", q, answer)
+		}
+		messages = append(messages, AssistantMessage(string(reply)), UserMessage(answers.String()))
+	}
+	final := len(rounds) >= MaxAnalystRounds
+	if final {
+		messages = append(messages, UserMessage(`That was the last round of questions. Return "valid" or "invalid" now and record anything still open under "constraints" as an assumption.`))
+	}
+
+	// Make the call
 	var response AnalystResponse
-	if err := json.Unmarshal([]byte(cleanJSON(content)), &response); err != nil {
-		return AnalystResponse{}, fmt.Errorf("failed to parse JSON: %v, content: %s", err, content)
+	err = a.completeJSON(ctx, messages, analystSchema, &response, func() error {
+		switch response.Status {
+		case "valid":
+			if len(response.Requirements.HappyPath) == 0 {
+				return fmt.Errorf(`status "valid" requires at least one happy_path step`)
+			}
+		case "invalid":
+		case "needs_info":
+			if final {
+				return fmt.Errorf(`no more questions allowed, status must be "valid" or "invalid"`)
+			}
+			if len(response.Questions) == 0 {
+				return fmt.Errorf(`status "needs_info" requires at least one question`)
+			}
+		default:
+			return fmt.Errorf("unknown status %q, expected valid, needs_info or invalid", response.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return AnalystResponse{}, err
 	}
 
 	return response, nil
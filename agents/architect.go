@@ -5,93 +5,149 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/openai/openai-go/v3"
+	"github.com/DN-OpenSource/nodey/flow"
 )
 
-type Flowchart struct {
-	Overview    Overview     `json:"overview"`
-	Nodes       []Node       `json:"nodes"`
-	Connections []Connection `json:"connections"`
-}
+// Metadata records how a flowchart was produced.
+type Metadata struct {
+	Agents map[string]Settings `json:"agents,omitempty"`
+
+	// Requirements is the Analyst's requirements document the flow was built from.
+	Requirements *Requirements `json:"requirements,omitempty"`
+
+	// Prompts identifies the system prompts the flow was produced with.
+	Prompts *PromptInfo `json:"prompts,omitempty"`
+
+	// Usage is the token usage and cost of the run that produced the flow.
+	Usage *Ledger `json:"usage,omitempty"`
+
+	// Research points at the cached research report the flow was built
+	// from, so edits can reuse it.
+	Research *ResearchRef `json:"research,omitempty"`
 
-type Overview struct {
-	Title   string `json:"title"`
-	Summary string `json:"summary"`
+	// Review is one of ReviewApproved, ReviewForceApproved or ReviewUnreviewed.
+	Review string `json:"review,omitempty"`
+
+	// Changes lists the patch operations applied when an existing flow was edited.
+	Changes []PatchOp `json:"changes,omitempty"`
+
+	// Revisions is how many drafts were rejected before this one; the
+	// full chain is saved in RevisionLog.
+	Revisions   int    `json:"revisions,omitempty"`
+	RevisionLog string `json:"revision_log,omitempty"`
 }
 
-type Node struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"` // trigger, action, decision, end
-	X     int    `json:"x"`
-	Y     int    `json:"y"`
-	Title string `json:"title"`
-	Notes string `json:"notes"` // Technical logic details
+// MetadataOf decodes the metadata saved with fc. It returns nil if fc has none.
+func MetadataOf(fc flow.Flowchart) (*Metadata, error) {
+	if len(fc.Meta) == 0 {
+		return nil, nil
+	}
+	var m Metadata
+	if err := json.Unmarshal(fc.Meta, &m); err != nil {
+		return nil, fmt.Errorf("meta: %w", err)
+	}
+	return &m, nil
 }
 
-type Connection struct {
-	From string `json:"from"`
-	To   string `json:"to"`
-	Type string `json:"type"` // out, yes, no
+// Attach stores m as the metadata of fc.
+func (m Metadata) Attach(fc *flow.Flowchart) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	fc.Meta = data
+	return nil
 }
 
-// GenerateFlowchart creates or updates a flowchart.
-func GenerateFlowchart(client *openai.Client, requirements string, research string, currentFlow *Flowchart) (Flowchart, error) {
-	sysPrompt := `You are a Flow Architect. Generate or Modify a JSON flowchart based on the requirements.
-Rules:
-1. Coordinates: Start at (100, 300). Vertical or Horizontal flow. Avoid overlapping.
-2. Nodes: Must have unique IDs. Types: "start", "trigger", "action", "decision", "end".
-   - "start": The entry point of the flow.
-   - "trigger": The event that initiates a process logic.
-   - "decision": Branching point (Requires 'yes' and 'no' connections).
-   - "action": A process step.
-   - "end": The final step.
-3. Content: 
-   - title: Short display name (e.g. "User Clicks").
-   - notes: Technical details (e.g. "API call to /v1/auth").
-4. Connections: valid "from" and "to" IDs.
-
-If an Existing Flow is provided, MODIFY it to meet the new requirements. Do not start over unless asked.
-Preserve existing IDs if possible.
-
-Example Output Structure:
-{
-  "overview": {"title": "Example Flow", "summary": "A simple flow"},
-  "nodes": [
-     {"id": "1", "type": "start", "x": 100, "y": 300, "title": "Start", "notes": "Entry point"},
-     {"id": "2", "type": "action", "x": 400, "y": 300, "title": "Process", "notes": "..."}
-  ],
-  "connections": [
-     {"from": "1", "to": "2", "type": "out"}
-  ]
+// Review outcomes recorded in Metadata.
+const (
+	ReviewApproved      = "approved"       // the judges approved the flow
+	ReviewForceApproved = "force_approved" // shipped after running out of revisions
+	ReviewUnreviewed    = "unreviewed"     // the judges could not review it
+)
+
+// Revision is one round of the draft/critique loop: the draft that was
+// reviewed and what the validator or the judges said about it.
+type Revision struct {
+	Round    int            `json:"round"`
+	Source   string         `json:"source"` // "validator" or "judges"
+	Draft    flow.Flowchart `json:"draft"`
+	Critique string         `json:"critique"`
+	Dissent  string         `json:"dissent,omitempty"`
 }
 
-CRITICAL: You MUST generate at least 2 nodes. Return strictly JSON.`
+// GenerateFlowchart creates or updates a flowchart. If revisions is not
+// empty, the Architect refines the latest rejected draft against every
+// critique so far instead of starting over.
+func GenerateFlowchart(ctx context.Context, a Agent, requirements string, research string, currentFlow *flow.Flowchart, revisions []Revision) (flow.Flowchart, error) {
+	sysPrompt, err := a.prompt(PromptArchitect, PromptData{NodeTypes: flow.NodeTypes()})
+	if err != nil {
+		return flow.Flowchart{}, err
+	}
 
 	input := fmt.Sprintf("Requirements: %s\n\nResearch: %s", requirements, research)
-	if currentFlow != nil {
-		currentJSON, _ := json.MarshalIndent(currentFlow, "", "  ")
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1]
+		draft := latest.Draft
+		draft.Meta = nil
+		draftJSON, _ := json.MarshalIndent(draft, "", "  ")
+		input += fmt.Sprintf("\n\nYour latest draft (round %d) was rejected. Revise it rather than starting over, and address every critique below.\n%s", latest.Round, string(draftJSON))
+		input += formatCritiques(revisions)
+	} else if currentFlow != nil {
+		existing := *currentFlow
+		existing.Meta = nil
+		currentJSON, _ := json.MarshalIndent(existing, "", "  ")
 		input += fmt.Sprintf("\n\nExisting Flowchart to Modify:\n%s", string(currentJSON))
 	}
 
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(sysPrompt),
-		openai.UserMessage(input),
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(input),
 	}
 
-	res, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: messages,
-		Model:    openai.ChatModelGPT5Nano2025_08_07,
+	var fc flow.Flowchart
+	err = a.completeJSON(ctx, messages, SchemaFor("flowchart", flow.Flowchart{}), &fc, func() error {
+		if len(fc.Nodes) == 0 {
+			return fmt.Errorf("generated flowchart has 0 nodes, likely invalid JSON or AI refusal")
+		}
+		return nil
 	})
 	if err != nil {
-		return Flowchart{}, err
+		return flow.Flowchart{}, err
 	}
+	if len(revisions) > 0 {
+		keepSubflows(&fc, revisions[len(revisions)-1].Draft)
+	} else if currentFlow != nil {
+		keepSubflows(&fc, *currentFlow)
+	}
+	return fc, nil
+}
 
-	var flow Flowchart
-	if err := json.Unmarshal([]byte(cleanJSON(res.Choices[0].Message.Content)), &flow); err != nil {
-		return Flowchart{}, err
+// keepSubflows copies the child flows of prev's subprocess nodes onto the
+// subprocess nodes of fc with the same id. Subflows are not part of the
+// Architect's schema, so a regenerated flow would otherwise lose them.
+func keepSubflows(fc *flow.Flowchart, prev flow.Flowchart) {
+	subflows := map[string]*flow.Subflow{}
+	for _, n := range prev.Nodes {
+		if n.Type == "subprocess" && n.Subflow != nil {
+			subflows[n.ID] = n.Subflow
+		}
+	}
+	for i, n := range fc.Nodes {
+		if n.Type == "subprocess" && n.Subflow == nil {
+			fc.Nodes[i].Subflow = subflows[n.ID]
+		}
 	}
-	if len(flow.Nodes) == 0 {
-		return Flowchart{}, fmt.Errorf("generated flowchart has 0 nodes, likely invalid JSON or AI refusal")
+}
+
+// formatCritiques lists the critique of every revision round, oldest first.
+func formatCritiques(revisions []Revision) string {
+	out := "\n\nCritiques so far (oldest first):"
+	for _, r := range revisions {
+		out += fmt.Sprintf("\nRound %d (%s): %s", r.Round, r.Source, r.Critique)
+		if r.Dissent != "" {
+			out += "\nDissent: " + r.Dissent
+		}
 	}
-	return flow, nil
+	return out
 }
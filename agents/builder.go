@@ -0,0 +1,389 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// MaxToolSteps bounds the number of model turns BuildFlowchart may take.
+// A turn can carry many tool calls, so this is far above the node count of
+// any flow the Architect is expected to build.
+const MaxToolSteps = 200
+
+// MaxIdleSteps is how many replies in a row BuildFlowchart accepts without
+// a tool call before it gives up. Every step resends the whole transcript,
+// so a model that only chats would otherwise be paid for MaxToolSteps times.
+const MaxIdleSteps = 3
+
+// Tool arguments. Their schemas are sent as the tool parameters.
+type (
+	addNodeArgs struct {
+		ID      string   `json:"id"`
+		Type    string   `json:"type" enum:"@node_types"`
+		Title   string   `json:"title"`
+		Notes   string   `json:"notes"`
+		Lane    string   `json:"lane"`
+		Sources []string `json:"sources"`
+	}
+	updateNodeArgs struct {
+		ID      string   `json:"id"`
+		Type    string   `json:"type"`
+		Title   string   `json:"title"`
+		Notes   string   `json:"notes"`
+		Lane    string   `json:"lane"`
+		Sources []string `json:"sources"`
+	}
+	nodeIDArgs struct {
+		ID string `json:"id"`
+	}
+	connectArgs struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Branch    string `json:"branch" enum:"out,yes,no,branch,default"`
+		Label     string `json:"label"`
+		Condition string `json:"condition"`
+	}
+	disconnectArgs struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	extractArgs struct {
+		ID      string   `json:"id"`
+		Title   string   `json:"title"`
+		Summary string   `json:"summary"`
+		Nodes   []string `json:"nodes"`
+	}
+	lanesArgs struct {
+		Lanes []string `json:"lanes"`
+	}
+	sectionArgs struct {
+		Heading string `json:"heading"`
+	}
+	doneArgs struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	noArgs struct{}
+)
+
+func toolFor(name, description string, args any) Tool {
+	return Tool{Name: name, Description: description, Parameters: SchemaFor(name, args).Schema}
+}
+
+// builderTools are built per run because the node types they offer
+// include the custom ones registered at startup.
+func builderTools() []Tool {
+	return []Tool{
+		toolFor("set_lanes", "Set the flow's swimlanes: every actor, in display order. Replaces the current list.", lanesArgs{}),
+		toolFor("add_node", "Add a node with a new, unique id. lane is the actor performing the step and must be one of the lanes.", addNodeArgs{}),
+		toolFor("update_node", "Change the type, title, notes, lane or sources of an existing node. Empty fields are left unchanged.", updateNodeArgs{}),
+		toolFor("remove_node", "Remove a node and all of its connections.", nodeIDArgs{}),
+		toolFor("connect", `Connect two existing nodes. Everything but a decision uses "out". A decision has either a "yes" and a "no" branch, or any number of "branch" connections with a unique label (e.g. "PayPal") plus an optional "default". condition optionally says when a branch is taken; label and condition may be empty.`, connectArgs{}),
+		toolFor("disconnect", "Remove every connection from one node to another.", disconnectArgs{}),
+		toolFor("extract_subflow", "Move a region of the flow into a child flow behind a new subprocess node with the given id. The region is listed by node id and must be entered through one node and lead to one node outside it.", extractArgs{}),
+		toolFor("list_nodes", "List every node with its outgoing connections.", noArgs{}),
+		toolFor("validate_flow", "Run the structural validator on the flow built so far.", noArgs{}),
+		toolFor("get_research_section", "Return one section of the research report by heading. An empty heading lists the headings.", sectionArgs{}),
+		toolFor("done", "Finish with the flow's title and summary. Refused while the validator still reports issues.", doneArgs{}),
+	}
+}
+
+// builder is the in-memory graph the Architect's tools work on.
+type builder struct {
+	flow     flow.Flowchart
+	ops      []PatchOp
+	research []section
+	done     bool
+}
+
+// BuildFlowchart lets the Architect construct the flow one tool call at a
+// time instead of in a single reply, so flows of any size fit and the
+// validator can point out mistakes while the graph is being built. It
+// starts from the latest rejected draft, else from current, else from an
+// empty graph, and returns the result together with every change applied.
+func BuildFlowchart(ctx context.Context, a Agent, requirements string, research string, current *flow.Flowchart, revisions []Revision) (flow.Flowchart, []PatchOp, error) {
+	sysPrompt, err := a.prompt(PromptArchitectTools, PromptData{NodeTypes: flow.NodeTypes()})
+	if err != nil {
+		return flow.Flowchart{}, nil, err
+	}
+
+	b := &builder{research: splitSections(research)}
+	switch {
+	case len(revisions) > 0:
+		b.flow = revisions[len(revisions)-1].Draft
+	case current != nil:
+		b.flow = *current
+	}
+	b.flow.Meta = nil
+
+	input := "Requirements: " + requirements
+	if headings := b.headings(); headings != "" {
+		input += "\n\nResearch sections (read them with get_research_section):\n" + headings
+	} else {
+		input += "\n\nResearch: " + research
+	}
+	if len(b.flow.Nodes) > 0 {
+		input += fmt.Sprintf("\n\nThe flow already has %d nodes. Call list_nodes to see them and edit them in place; do not start over.", len(b.flow.Nodes))
+	}
+	if len(revisions) > 0 {
+		input += "\n\nThis is your latest draft and it was rejected. Address every critique below." + formatCritiques(revisions)
+	}
+
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(input),
+	}
+
+	idle := 0 // replies in a row without a tool call
+	for step := 1; step <= MaxToolSteps; step++ {
+		res, err := a.completeTools(ctx, messages, builderTools())
+		if err != nil {
+			return flow.Flowchart{}, nil, err
+		}
+		messages = append(messages, Message{Role: RoleAssistant, Content: res.Content, ToolCalls: res.ToolCalls})
+
+		if len(res.ToolCalls) == 0 {
+			if idle++; idle >= MaxIdleSteps {
+				return flow.Flowchart{}, nil, fmt.Errorf("%s replied %d times in a row without calling a tool; the model may not support function calling", a.Name, idle)
+			}
+			messages = append(messages, UserMessage("Keep building the flow with the tools and call done when it is complete."))
+			continue
+		}
+		idle = 0
+		for _, call := range res.ToolCalls {
+			messages = append(messages, ToolMessage(call.ID, b.call(a, call)))
+		}
+		if a.Progress != nil {
+			partial, _ := json.Marshal(b.flow)
+			a.Progress(string(partial))
+		}
+		if b.done {
+			return b.flow, b.ops, nil
+		}
+	}
+	return flow.Flowchart{}, nil, fmt.Errorf("%s did not call done within %d steps", a.Name, MaxToolSteps)
+}
+
+// call runs one tool call and returns the text sent back to the model.
+// Mistakes are reported to the model rather than returned as errors.
+func (b *builder) call(a Agent, call ToolCall) string {
+	decode := func(v any) error {
+		if strings.TrimSpace(call.Arguments) == "" {
+			return nil
+		}
+		if err := decodeJSON(call.Arguments, v); err != nil {
+			return fmt.Errorf("invalid arguments: %v", err)
+		}
+		return nil
+	}
+	result := func(err error, ok string) string {
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return ok
+	}
+
+	switch call.Name {
+	case "add_node":
+		var args addNodeArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "add_node", ID: args.ID, Type: args.Type, Title: args.Title, Notes: args.Notes, Lane: args.Lane, Sources: args.Sources})
+		return result(err, fmt.Sprintf("added %s (%d nodes)", args.ID, len(b.flow.Nodes)))
+
+	case "update_node":
+		var args updateNodeArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "update_node", ID: args.ID, Type: args.Type, Title: args.Title, Notes: args.Notes, Lane: args.Lane, Sources: args.Sources})
+		return result(err, "updated "+args.ID)
+
+	case "set_lanes":
+		var args lanesArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "set_lanes", Lanes: args.Lanes})
+		return result(err, fmt.Sprintf("%d lanes", len(args.Lanes)))
+
+	case "remove_node":
+		var args nodeIDArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "remove_node", ID: args.ID})
+		return result(err, "removed "+args.ID)
+
+	case "connect":
+		var args connectArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "connect", From: args.From, To: args.To, Branch: args.Branch, Label: args.Label, Condition: args.Condition})
+		return result(err, fmt.Sprintf("connected %s -> %s", args.From, args.To))
+
+	case "disconnect":
+		var args disconnectArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "disconnect", From: args.From, To: args.To})
+		return result(err, fmt.Sprintf("disconnected %s -> %s", args.From, args.To))
+
+	case "extract_subflow":
+		var args extractArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		err := b.apply(PatchOp{Op: "extract_subflow", ID: args.ID, Title: args.Title, Summary: args.Summary, Nodes: args.Nodes})
+		return result(err, fmt.Sprintf("extracted %d nodes into %s", len(args.Nodes), args.ID))
+
+	case "list_nodes":
+		return b.list()
+
+	case "validate_flow":
+		if issues := Validate(b.flow); len(issues) > 0 {
+			return fmt.Sprintf("%d issues:\n%s", len(issues), FormatIssues(issues))
+		}
+		return "ok: no structural issues"
+
+	case "get_research_section":
+		var args sectionArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		return b.section(args.Heading)
+
+	case "done":
+		var args doneArgs
+		if err := decode(&args); err != nil {
+			return result(err, "")
+		}
+		if issues := Validate(b.flow); len(issues) > 0 {
+			a.notify(fmt.Sprintf("Tried to finish with %d structural issues; asked to fix them.", len(issues)))
+			return fmt.Sprintf("error: not done, fix these %d issues first:\n%s", len(issues), FormatIssues(issues))
+		}
+		if args.Title != "" || args.Summary != "" {
+			b.apply(PatchOp{Op: "retitle", Title: args.Title, Summary: args.Summary})
+		}
+		b.done = true
+		return "ok"
+	}
+	return fmt.Sprintf("error: unknown tool %q", call.Name)
+}
+
+// apply applies a single patch operation and records it on success.
+func (b *builder) apply(op PatchOp) error {
+	flow, err := ApplyPatch(b.flow, []PatchOp{op})
+	if err != nil {
+		return err
+	}
+	b.flow = flow
+	b.ops = append(b.ops, op)
+	return nil
+}
+
+// list renders the graph compactly, one node per line.
+func (b *builder) list() string {
+	if len(b.flow.Nodes) == 0 {
+		return "the flow is empty"
+	}
+	out := map[string][]string{}
+	for _, c := range b.flow.Connections {
+		branch := c.Type
+		if c.Label != "" {
+			branch += " " + strconv.Quote(c.Label)
+		}
+		if c.Condition != "" {
+			branch += " when " + c.Condition
+		}
+		out[c.From] = append(out[c.From], fmt.Sprintf("%s (%s)", c.To, branch))
+	}
+	var sb strings.Builder
+	if len(b.flow.Lanes) > 0 {
+		sb.WriteString("lanes: " + strings.Join(b.flow.Lanes, ", ") + "\n")
+	}
+	for _, n := range b.flow.Nodes {
+		fmt.Fprintf(&sb, "%s %s %q", n.ID, n.Type, n.Title)
+		if n.Lane != "" {
+			fmt.Fprintf(&sb, " [%s]", n.Lane)
+		}
+		if sub := n.Subflow; sub != nil {
+			if sub.Flow != nil {
+				fmt.Fprintf(&sb, " {subflow of %d nodes}", len(sub.Flow.Nodes))
+			} else {
+				fmt.Fprintf(&sb, " {subflow %s}", sub.Ref)
+			}
+		}
+		if edges := out[n.ID]; len(edges) > 0 {
+			sb.WriteString(" -> " + strings.Join(edges, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// section is one heading of the research report and the text below it.
+type section struct {
+	heading string
+	body    string
+}
+
+// splitSections splits a Markdown report at its headings. Text before the
+// first heading is dropped into an "Introduction" section.
+func splitSections(report string) []section {
+	var sections []section
+	var cur *section
+	for _, line := range strings.Split(report, "\n") {
+		if strings.HasPrefix(line, "#") {
+			sections = append(sections, section{heading: strings.TrimSpace(strings.TrimLeft(line, "#"))})
+			cur = &sections[len(sections)-1]
+			continue
+		}
+		if cur == nil {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			sections = append(sections, section{heading: "Introduction"})
+			cur = &sections[len(sections)-1]
+		}
+		cur.body += line + "\n"
+	}
+	// A report without any real heading is not worth sectioning.
+	if len(sections) == 1 && sections[0].heading == "Introduction" {
+		return nil
+	}
+	return sections
+}
+
+func (b *builder) headings() string {
+	lines := make([]string, len(b.research))
+	for i, s := range b.research {
+		lines[i] = "- " + s.heading
+	}
+	return strings.Join(lines, "\n")
+}
+
+// section returns the first section whose heading contains heading,
+// ignoring case.
+func (b *builder) section(heading string) string {
+	if heading == "" {
+		if len(b.research) == 0 {
+			return "the research report has no sections"
+		}
+		return b.headings()
+	}
+	for _, s := range b.research {
+		if strings.Contains(strings.ToLower(s.heading), strings.ToLower(heading)) {
+			return strings.TrimSpace(s.body)
+		}
+	}
+	return fmt.Sprintf("error: no section matches %q, available:\n%s", heading, b.headings())
+}
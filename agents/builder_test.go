@@ -0,0 +1,121 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func toolCall(id, name, args string) ToolCall {
+	return ToolCall{ID: id, Name: name, Arguments: args}
+}
+
+func TestBuildFlowchart(t *testing.T) {
+	p := &ScriptedProvider{}
+	p.PushToolCalls(
+		toolCall("1", "add_node", `{"id": "n1", "type": "start", "title": "Begin", "notes": "", "lane": "", "sources": []}`),
+		toolCall("2", "add_node", `{"id": "n2", "type": "end", "title": "Finish", "notes": "", "lane": "", "sources": []}`),
+	)
+	p.PushToolCalls(toolCall("3", "connect", `{"from": "n1", "to": "n2", "branch": "out", "label": "", "condition": ""}`))
+	p.PushToolCalls(toolCall("4", "done", `{"title": "Tiny", "summary": "Two steps."}`))
+
+	fc, ops, err := BuildFlowchart(context.Background(), Agent{Name: Architect, Provider: p}, "reqs", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Nodes) != 2 || len(fc.Connections) != 1 || fc.Connections[0].Type != "out" {
+		t.Errorf("built %d nodes and %+v, want n1 -> n2", len(fc.Nodes), fc.Connections)
+	}
+	if fc.Overview.Title != "Tiny" || fc.Overview.Summary != "Two steps." {
+		t.Errorf("overview is %+v", fc.Overview)
+	}
+	var names []string
+	for _, op := range ops {
+		names = append(names, op.Op)
+	}
+	if got := strings.Join(names, " "); got != "add_node add_node connect retitle" {
+		t.Errorf("ops are %q", got)
+	}
+	if len(p.Requests) != 3 {
+		t.Errorf("made %d calls, want 3", len(p.Requests))
+	}
+}
+
+func TestBuildFlowchartInvalidToolCalls(t *testing.T) {
+	p := &ScriptedProvider{}
+	p.PushToolCalls(
+		toolCall("1", "add_node", `{"id": "n1", "type": `),
+		toolCall("2", "add_node", `{"id": "n1", "type": "nonsense", "title": "", "notes": "", "lane": "", "sources": []}`),
+		toolCall("3", "teleport", `{}`),
+		toolCall("4", "connect", `{"from": "n1", "to": "n9", "branch": "out", "label": "", "condition": ""}`),
+	)
+	p.PushToolCalls(
+		toolCall("5", "add_node", `{"id": "n1", "type": "start", "title": "Begin", "notes": "", "lane": "", "sources": []}`),
+		toolCall("6", "done", `{"title": "", "summary": ""}`),
+	)
+	p.PushToolCalls(
+		toolCall("7", "add_node", `{"id": "n2", "type": "end", "title": "Finish", "notes": "", "lane": "", "sources": []}`),
+		toolCall("8", "connect", `{"from": "n1", "to": "n2", "branch": "out", "label": "", "condition": ""}`),
+		toolCall("9", "done", `{"title": "", "summary": ""}`),
+	)
+
+	var notes []string
+	a := Agent{Name: Architect, Provider: p, Notify: func(s string) { notes = append(notes, s) }}
+	fc, _, err := BuildFlowchart(context.Background(), a, "reqs", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Nodes) != 2 || len(fc.Connections) != 1 {
+		t.Errorf("built %d nodes and %d connections, want 2 and 1", len(fc.Nodes), len(fc.Connections))
+	}
+
+	// Every mistake is reported back to the model as a tool result.
+	results := map[string]string{}
+	for _, m := range p.Requests[2].Messages {
+		if m.Role == RoleTool {
+			results[m.ToolCallID] = m.Content
+		}
+	}
+	for id, want := range map[string]string{
+		"1": "error: invalid arguments",
+		"2": "error: ",
+		"3": `error: unknown tool "teleport"`,
+		"4": "error: ",
+		"6": "error: not done",
+	} {
+		if !strings.HasPrefix(results[id], want) {
+			t.Errorf("result of call %s is %q, want it to start with %q", id, results[id], want)
+		}
+	}
+	if len(notes) != 1 {
+		t.Errorf("got notes %q, want one about the refused done", notes)
+	}
+}
+
+func TestBuildFlowchartWithoutToolCalls(t *testing.T) {
+	p := NewScriptedProvider("Sure, here is the flow:", "It starts with a login.", "Then it ends.", "Anything else?")
+
+	_, _, err := BuildFlowchart(context.Background(), Agent{Name: Architect, Provider: p}, "reqs", "", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "without calling a tool") {
+		t.Fatalf("got error %v, want the model to be given up on", err)
+	}
+	if len(p.Requests) != MaxIdleSteps {
+		t.Errorf("made %d calls, want %d", len(p.Requests), MaxIdleSteps)
+	}
+}
+
+func TestBuildFlowchartIdleCountResets(t *testing.T) {
+	p := NewScriptedProvider("Let me think.", "Still thinking.")
+	p.PushToolCalls(toolCall("1", "add_node", `{"id": "n1", "type": "start", "title": "Begin", "notes": "", "lane": "", "sources": []}`))
+	p.Push(Response{Content: "Hmm."})
+	p.Push(Response{Content: "Almost."})
+	p.PushToolCalls(
+		toolCall("2", "add_node", `{"id": "n2", "type": "end", "title": "Finish", "notes": "", "lane": "", "sources": []}`),
+		toolCall("3", "connect", `{"from": "n1", "to": "n2", "branch": "out", "label": "", "condition": ""}`),
+		toolCall("4", "done", `{"title": "", "summary": ""}`),
+	)
+
+	if _, _, err := BuildFlowchart(context.Background(), Agent{Name: Architect, Provider: p}, "reqs", "", nil, nil); err != nil {
+		t.Fatalf("a tool call should reset the count of idle replies: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package agents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Cassette modes.
+const (
+	CassetteRecord = "record"
+	CassetteReplay = "replay"
+)
+
+// cassette is the on-disk form of one recorded completion.
+type cassette struct {
+	Key      string   `json:"key"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// CassetteKey identifies a request by a hash of its model and messages.
+// Identical prompts to the same model always map to the same cassette.
+func CassetteKey(req Request) string {
+	data, _ := json.Marshal(struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+	}{req.Model, req.Messages})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func cassettePath(dir string, req Request) string {
+	return filepath.Join(dir, CassetteKey(req)[:16]+".json")
+}
+
+// RecordingProvider forwards every request to Inner and writes the
+// request/response pair to Dir so it can be replayed later.
+type RecordingProvider struct {
+	Inner Provider
+	Dir   string
+}
+
+// Complete implements Provider.
+func (p *RecordingProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	res, err := p.Inner.Complete(ctx, req)
+	if err != nil {
+		return res, err
+	}
+
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		return res, fmt.Errorf("failed to create cassette dir: %w", err)
+	}
+	data, err := json.MarshalIndent(cassette{Key: CassetteKey(req), Request: req, Response: res}, "", "  ")
+	if err != nil {
+		return res, err
+	}
+	if err := os.WriteFile(cassettePath(p.Dir, req), data, 0644); err != nil {
+		return res, fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return res, nil
+}
+
+// ReplayProvider serves responses recorded by RecordingProvider without
+// touching the network. A request that was never recorded is an error.
+type ReplayProvider struct {
+	Dir string
+}
+
+// Complete implements Provider.
+func (p *ReplayProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	path := cassettePath(p.Dir, req)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Response{}, fmt.Errorf("no cassette for %s request %s in %s", req.Model, CassetteKey(req)[:16], p.Dir)
+	}
+	if err != nil {
+		return Response{}, err
+	}
+
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Response{}, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if c.Key != CassetteKey(req) {
+		return Response{}, fmt.Errorf("cassette %s does not match request (hash prefix collision)", path)
+	}
+	if req.Stream != nil {
+		req.Stream(c.Response.Content)
+	}
+	return c.Response, nil
+}
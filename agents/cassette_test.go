@@ -0,0 +1,87 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCassetteKey(t *testing.T) {
+	req := Request{Model: "m", Messages: []Message{SystemMessage("sys"), UserMessage("hi")}}
+
+	same := req
+	same.Options = Options{MaxTokens: 100}
+	same.Stream = func(string) {}
+	if CassetteKey(req) != CassetteKey(same) {
+		t.Error("options and streaming changed the key; only the model and messages should")
+	}
+
+	for name, other := range map[string]Request{
+		"model":   {Model: "other", Messages: req.Messages},
+		"message": {Model: "m", Messages: []Message{SystemMessage("sys"), UserMessage("hello")}},
+		"role":    {Model: "m", Messages: []Message{SystemMessage("sys"), AssistantMessage("hi")}},
+		"extra":   {Model: "m", Messages: append(append([]Message(nil), req.Messages...), UserMessage("more"))},
+	} {
+		if CassetteKey(req) == CassetteKey(other) {
+			t.Errorf("a different %s gave the same key", name)
+		}
+	}
+	if k := CassetteKey(req); len(k) != 64 {
+		t.Errorf("key %q is not a hex SHA-256", k)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	req := Request{Model: "m", Messages: []Message{UserMessage("hi")}}
+	want := Response{Content: "hello", Usage: Usage{PromptTokens: 3, CompletionTokens: 1}}
+
+	inner := &ScriptedProvider{}
+	inner.Push(want)
+	rec := &RecordingProvider{Inner: inner, Dir: dir}
+	if _, err := rec.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("recorded %d cassettes, want 1", len(files))
+	}
+
+	var streamed strings.Builder
+	req.Stream = func(delta string) { streamed.WriteString(delta) }
+	got, err := (&ReplayProvider{Dir: dir}).Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != want.Content || got.Usage != want.Usage {
+		t.Errorf("replayed %+v, want %+v", got, want)
+	}
+	if streamed.String() != want.Content {
+		t.Errorf("streamed %q, want %q", streamed.String(), want.Content)
+	}
+}
+
+func TestRecordSkipsFailures(t *testing.T) {
+	dir := t.TempDir()
+	inner := &ScriptedProvider{}
+	inner.PushError(errors.New("boom"))
+	rec := &RecordingProvider{Inner: inner, Dir: dir}
+	if _, err := rec.Complete(context.Background(), Request{Model: "m"}); err == nil {
+		t.Fatal("expected the inner error")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("recorded %d cassettes for a failed call", len(files))
+	}
+}
+
+func TestReplayMiss(t *testing.T) {
+	req := Request{Model: "m", Messages: []Message{UserMessage("never recorded")}}
+	_, err := (&ReplayProvider{Dir: t.TempDir()}).Complete(context.Background(), req)
+	if err == nil {
+		t.Fatal("expected an error for a request that was never recorded")
+	}
+	if want := CassetteKey(req)[:16]; !strings.Contains(err.Error(), "no cassette") || !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not name the missing cassette %s", err, want)
+	}
+}
@@ -0,0 +1,72 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ScriptedProvider is an in-memory Provider that replays canned responses in
+// order. It records every request it receives, which makes it handy for
+// exercising the agent pipeline without a network.
+type ScriptedProvider struct {
+	mu        sync.Mutex
+	responses []Response
+	errs      []error
+	Requests  []Request
+}
+
+// NewScriptedProvider returns a ScriptedProvider that answers with the given
+// contents, one per call.
+func NewScriptedProvider(contents ...string) *ScriptedProvider {
+	p := &ScriptedProvider{}
+	for _, c := range contents {
+		p.Push(Response{Content: c})
+	}
+	return p
+}
+
+// Push appends a successful response to the script.
+func (p *ScriptedProvider) Push(res Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, res)
+	p.errs = append(p.errs, nil)
+}
+
+// PushToolCalls appends a response that calls the given tools.
+func (p *ScriptedProvider) PushToolCalls(calls ...ToolCall) {
+	p.Push(Response{ToolCalls: calls})
+}
+
+// PushError appends a failing call to the script.
+func (p *ScriptedProvider) PushError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, Response{})
+	p.errs = append(p.errs, err)
+}
+
+// Complete implements Provider.
+func (p *ScriptedProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Requests = append(p.Requests, req)
+
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	if len(p.responses) == 0 {
+		return Response{}, fmt.Errorf("scripted provider: no response left for call %d", len(p.Requests))
+	}
+	res, err := p.responses[0], p.errs[0]
+	p.responses, p.errs = p.responses[1:], p.errs[1:]
+	if err == nil && req.Stream != nil {
+		// Stream line by line, like a slow model would
+		for _, line := range strings.SplitAfter(res.Content, "\n") {
+			req.Stream(line)
+		}
+	}
+	return res, err
+}
@@ -0,0 +1,58 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScriptedProvider(t *testing.T) {
+	p := NewScriptedProvider("first", "line 1\nline 2")
+	p.PushError(errors.New("overloaded"))
+	ctx := context.Background()
+
+	if res, err := p.Complete(ctx, Request{Model: "a"}); err != nil || res.Content != "first" {
+		t.Errorf("call 1 gave %q, %v", res.Content, err)
+	}
+	var deltas []string
+	res, err := p.Complete(ctx, Request{Model: "b", Stream: func(d string) { deltas = append(deltas, d) }})
+	if err != nil || res.Content != "line 1\nline 2" || strings.Join(deltas, "|") != "line 1\n|line 2" {
+		t.Errorf("call 2 gave %q, %v and streamed %q", res.Content, err, deltas)
+	}
+	if _, err := p.Complete(ctx, Request{Model: "c"}); err == nil || err.Error() != "overloaded" {
+		t.Errorf("call 3 gave %v, want the scripted error", err)
+	}
+	if len(p.Requests) != 3 || p.Requests[2].Model != "c" {
+		t.Errorf("recorded %+v", p.Requests)
+	}
+}
+
+func TestScriptedProviderRunsOut(t *testing.T) {
+	p := NewScriptedProvider("only")
+	ctx := context.Background()
+	p.Complete(ctx, Request{})
+
+	// An unexpected extra call is reported with its position, and recorded
+	// so a test can see what was asked.
+	_, err := p.Complete(ctx, Request{Model: "extra", Messages: []Message{UserMessage("one more?")}})
+	if err == nil || !strings.Contains(err.Error(), "no response left for call 2") {
+		t.Errorf("got %v, want the script to be reported as used up", err)
+	}
+	if len(p.Requests) != 2 || p.Requests[1].Messages[0].Content != "one more?" {
+		t.Errorf("the extra call was not recorded: %+v", p.Requests)
+	}
+}
+
+func TestScriptedProviderCancelled(t *testing.T) {
+	p := NewScriptedProvider("kept")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Complete(ctx, Request{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	// The cancelled call does not use up the response.
+	if res, err := p.Complete(context.Background(), Request{}); err != nil || res.Content != "kept" {
+		t.Errorf("got %q, %v after a cancelled call", res.Content, err)
+	}
+}
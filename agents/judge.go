@@ -2,11 +2,8 @@ package agents
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
-
-	"github.com/openai/openai-go/v3"
 )
 
 type JudgeResponse struct {
@@ -15,40 +12,29 @@ type JudgeResponse struct {
 	Dissent  string `json:"dissent"` // If approved=false, explain why
 }
 
-func Judgement(client *openai.Client, flowchartJSON string, requirements string) (JudgeResponse, error) {
-	sysPrompt := `You are a panel of 3 Senior Software Architects acting as Judges.
-Review the provided Flowchart JSON against the Requirements.
-Vote on whether it is valid, complete, and technically sound.
-
-If UNANIMOUS APPROVAL: return "approved": true.
-If ANY DISAGREEMENT or MAJOR ISSUES: return "approved": false and provide "critique" and "dissent".
-
-Critique should be constructive.
-Ensure nodes are not overlapping (check coordinates).
-Ensure logic flows correctly. It MUST have a 'start' and 'end' node.`
-
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(sysPrompt),
-		openai.UserMessage(fmt.Sprintf("Requirements: %s\n\nFlowchart JSON: %s", requirements, flowchartJSON)),
-	}
+var judgeSchema = SchemaFor("judge_response", JudgeResponse{})
 
-	res, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: messages,
-		Model:    openai.ChatModelGPT5Nano2025_08_07,
-	})
+// Judgement asks a single Senior Software Architect to review the flowchart.
+// persona, if not empty, narrows the review to one concern (see Personas).
+func Judgement(ctx context.Context, a Agent, persona string, flowchartJSON string, requirements string) (JudgeResponse, error) {
+	sysPrompt, err := a.prompt(PromptJudge, PromptData{Focus: personaPrompt(persona)})
 	if err != nil {
 		return JudgeResponse{}, err
 	}
 
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(fmt.Sprintf("Requirements: %s\n\nFlowchart JSON: %s", requirements, flowchartJSON)),
+	}
+
 	var resp JudgeResponse
-	if err := json.Unmarshal([]byte(cleanJSON(res.Choices[0].Message.Content)), &resp); err != nil {
-		// Fallback if strict JSON fails, try to parse manually or return error
-		// For now, assuming GPT4o follows instructions well.
-		return JudgeResponse{}, fmt.Errorf("failed to parse judge response: %v", err)
+	if err := a.completeJSON(ctx, messages, judgeSchema, &resp, nil); err != nil {
+		return JudgeResponse{}, err
 	}
 
 	// Normalize strings
 	resp.Critique = strings.TrimSpace(resp.Critique)
+	resp.Dissent = strings.TrimSpace(resp.Dissent)
 
 	return resp, nil
 }
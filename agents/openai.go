@@ -0,0 +1,185 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// OpenAIProvider talks to the OpenAI Chat Completions API or any server that
+// speaks the same protocol (Ollama, LM Studio, vLLM, ...).
+type OpenAIProvider struct {
+	client openai.Client
+
+	// StructuredOutputs enables schema-constrained responses
+	// (response_format json_schema) for requests that carry a Schema.
+	StructuredOutputs bool
+}
+
+// NewOpenAIProvider returns a Provider for api.openai.com.
+// The client's own retries are off; agents retry with their RetryPolicy.
+func NewOpenAIProvider(apiKey string) *OpenAIProvider {
+	return &OpenAIProvider{
+		client:            openai.NewClient(option.WithAPIKey(apiKey), option.WithMaxRetries(0)),
+		StructuredOutputs: true,
+	}
+}
+
+// NewOpenAICompatibleProvider returns a Provider for a self-hosted,
+// OpenAI-compatible endpoint such as "http://localhost:11434/v1".
+// Most local servers ignore the API key, so it may be empty.
+// Structured outputs are off by default since support varies by server.
+func NewOpenAICompatibleProvider(baseURL, apiKey string) *OpenAIProvider {
+	if apiKey == "" {
+		apiKey = "unused"
+	}
+	return &OpenAIProvider{client: openai.NewClient(
+		option.WithBaseURL(baseURL),
+		option.WithAPIKey(apiKey),
+		option.WithMaxRetries(0),
+	)}
+}
+
+// Complete implements Provider.
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	params := toOpenAIParams(req)
+	if p.StructuredOutputs && req.Schema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   req.Schema.Name,
+					Schema: req.Schema.Schema,
+					Strict: openai.Bool(true),
+				},
+			},
+		}
+	}
+
+	if req.Stream != nil {
+		return p.stream(ctx, params, req.Stream)
+	}
+
+	res, err := p.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return Response{}, apiError(err)
+	}
+	if len(res.Choices) == 0 {
+		return Response{}, fmt.Errorf("provider returned no choices")
+	}
+	return fromOpenAIResponse(res.Choices[0].Message, res.Usage), nil
+}
+
+// stream runs a streaming completion, forwarding every content delta.
+func (p *OpenAIProvider) stream(ctx context.Context, params openai.ChatCompletionNewParams, onDelta func(string)) (Response, error) {
+	params.StreamOptions.IncludeUsage = openai.Bool(true)
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return Response{}, apiError(err)
+	}
+	if len(acc.Choices) == 0 {
+		return Response{}, fmt.Errorf("provider returned no choices")
+	}
+	return fromOpenAIResponse(acc.Choices[0].Message, acc.Usage), nil
+}
+
+// apiError wraps HTTP errors from the client in an APIError.
+func apiError(err error) error {
+	var oe *openai.Error
+	if !errors.As(err, &oe) {
+		return err
+	}
+	apiErr := &APIError{StatusCode: oe.StatusCode, Err: err}
+	if oe.Response != nil {
+		apiErr.RetryAfter = ParseRetryAfter(oe.Response.Header)
+	}
+	return apiErr
+}
+
+func fromOpenAIResponse(msg openai.ChatCompletionMessage, usage openai.CompletionUsage) Response {
+	res := Response{
+		Content: msg.Content,
+		Usage:   Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens},
+	}
+	for _, call := range msg.ToolCalls {
+		if call.Type != "function" {
+			continue
+		}
+		res.ToolCalls = append(res.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return res
+}
+
+func toOpenAIParams(req Request) openai.ChatCompletionNewParams {
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		switch m.Role {
+		case RoleSystem:
+			messages = append(messages, openai.SystemMessage(m.Content))
+		case RoleAssistant:
+			if len(m.ToolCalls) == 0 {
+				messages = append(messages, openai.AssistantMessage(m.Content))
+				continue
+			}
+			assistant := openai.ChatCompletionAssistantMessageParam{}
+			if m.Content != "" {
+				assistant.Content.OfString = openai.String(m.Content)
+			}
+			for _, call := range m.ToolCalls {
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID: call.ID,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+							Name:      call.Name,
+							Arguments: call.Arguments,
+						},
+					},
+				})
+			}
+			messages = append(messages, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+		case RoleTool:
+			messages = append(messages, openai.ToolMessage(m.Content, m.ToolCallID))
+		default:
+			messages = append(messages, openai.UserMessage(m.Content))
+		}
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Messages: messages,
+		Model:    shared.ChatModel(req.Model),
+	}
+	for _, t := range req.Tools {
+		params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(shared.FunctionDefinitionParam{
+			Name:        t.Name,
+			Description: openai.String(t.Description),
+			Parameters:  shared.FunctionParameters(t.Parameters),
+		}))
+	}
+	if req.Options.Temperature != nil {
+		params.Temperature = openai.Float(*req.Options.Temperature)
+	}
+	if req.Options.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(req.Options.MaxTokens)
+	}
+	if req.Options.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.Options.ReasoningEffort)
+	}
+	return params
+}
@@ -0,0 +1,221 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// completion is a minimal Chat Completions reply.
+func completion(content string, promptTokens, completionTokens int) string {
+	return fmt.Sprintf(`{"id": "c1", "object": "chat.completion", "created": 0, "model": "m",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": %q}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": %d, "completion_tokens": %d, "total_tokens": %d}}`,
+		content, promptTokens, completionTokens, promptTokens+completionTokens)
+}
+
+// reply writes a JSON response, as the API does for successes and errors.
+func reply(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+// standIn serves handler as an OpenAI-compatible API under /v1 and
+// returns a provider pointed at it.
+func standIn(t *testing.T, handler http.HandlerFunc) *OpenAIProvider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewOpenAICompatibleProvider(srv.URL+"/v1", "")
+}
+
+func testAgent(p Provider, policy RetryPolicy, events *[]RetryEvent) Agent {
+	return Agent{
+		Name:     Architect,
+		Provider: p,
+		Settings: Settings{Model: "m"},
+		Retry:    policy,
+		OnRetry:  func(e RetryEvent) { *events = append(*events, e) },
+	}
+}
+
+func TestRetryAfterThenSuccess(t *testing.T) {
+	var calls atomic.Int32
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0.2")
+			reply(w, http.StatusTooManyRequests, `{"error": {"message": "slow down", "type": "rate_limit"}}`)
+			return
+		}
+		reply(w, http.StatusOK, completion("ok", 1, 1))
+	})
+
+	var events []RetryEvent
+	a := testAgent(p, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}, &events)
+	start := time.Now()
+	res, err := a.send(context.Background(), Request{Messages: []Message{UserMessage("hi")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "ok" {
+		t.Errorf("content is %q", res.Content)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("server saw %d attempts, want 2", n)
+	}
+	if len(events) != 1 || events[0].Attempt != 1 || events[0].Wait != 200*time.Millisecond {
+		t.Fatalf("retry events %+v, want one 200ms wait after attempt 1", events)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("retried after %s, before Retry-After ran out", elapsed)
+	}
+	var apiErr *APIError
+	if !errors.As(events[0].Err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("retry was for %v, want a 429", events[0].Err)
+	}
+}
+
+func TestServerErrorsExhaustRetries(t *testing.T) {
+	var calls atomic.Int32
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		reply(w, http.StatusServiceUnavailable, `{"error": {"message": "overloaded"}}`)
+	})
+
+	var events []RetryEvent
+	a := testAgent(p, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}, &events)
+	_, err := a.send(context.Background(), Request{Messages: []Message{UserMessage("hi")}}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want the last 503", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("server saw %d attempts, want 3", n)
+	}
+	if len(events) != 2 {
+		t.Errorf("got %d retry events, want 2", len(events))
+	}
+}
+
+func TestBadRequestIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		reply(w, http.StatusBadRequest, `{"error": {"message": "bad schema"}}`)
+	})
+
+	var events []RetryEvent
+	a := testAgent(p, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, &events)
+	_, err := a.send(context.Background(), Request{Messages: []Message{UserMessage("hi")}}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got error %v, want a 400", err)
+	}
+	if n := calls.Load(); n != 1 || len(events) != 0 {
+		t.Errorf("server saw %d attempts and %d retries, want 1 and none", n, len(events))
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stream        bool `json:"stream"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream || !body.StreamOptions.IncludeUsage {
+			t.Errorf("request asked for stream=%v include_usage=%v", body.Stream, body.StreamOptions.IncludeUsage)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo, ", "world"} {
+			fmt.Fprintf(w, "data: {\"id\": \"c1\", \"object\": \"chat.completion.chunk\", \"created\": 0, \"model\": \"m\", \"choices\": [{\"index\": 0, \"delta\": {\"content\": %q}, \"finish_reason\": null}]}\n\n", delta)
+		}
+		io.WriteString(w, "data: {\"id\": \"c1\", \"object\": \"chat.completion.chunk\", \"created\": 0, \"model\": \"m\", \"choices\": [], \"usage\": {\"prompt_tokens\": 7, \"completion_tokens\": 3, \"total_tokens\": 10}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+
+	var deltas []string
+	res, err := p.Complete(context.Background(), Request{
+		Model:    "m",
+		Messages: []Message{UserMessage("hi")},
+		Stream:   func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(deltas, "|"); got != "Hel|lo, |world" {
+		t.Errorf("deltas are %q", got)
+	}
+	if res.Content != "Hello, world" {
+		t.Errorf("content is %q", res.Content)
+	}
+	if res.Usage != (Usage{PromptTokens: 7, CompletionTokens: 3}) {
+		t.Errorf("usage is %+v", res.Usage)
+	}
+}
+
+func TestUsageAndToolCalls(t *testing.T) {
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusOK, `{"id": "c1", "object": "chat.completion", "created": 0, "model": "m",
+			"choices": [{"index": 0, "finish_reason": "tool_calls", "message": {"role": "assistant", "content": "",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "add_node", "arguments": "{\"id\": \"n1\"}"}}]}}],
+			"usage": {"prompt_tokens": 120, "completion_tokens": 30, "total_tokens": 150}}`)
+	})
+
+	res, err := p.Complete(context.Background(), Request{Model: "m", Messages: []Message{UserMessage("hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Usage != (Usage{PromptTokens: 120, CompletionTokens: 30}) {
+		t.Errorf("usage is %+v", res.Usage)
+	}
+	want := ToolCall{ID: "call_1", Name: "add_node", Arguments: `{"id": "n1"}`}
+	if len(res.ToolCalls) != 1 || res.ToolCalls[0] != want {
+		t.Errorf("tool calls are %+v, want %+v", res.ToolCalls, want)
+	}
+}
+
+func TestCompatibleBaseURL(t *testing.T) {
+	var body map[string]any
+	p := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request went to %s, want /v1/chat/completions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer unused" {
+			t.Errorf("Authorization is %q, want a placeholder key", got)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		reply(w, http.StatusOK, completion("ok", 1, 1))
+	})
+	req := Request{Model: "llama3", Messages: []Message{UserMessage("hi")}, Schema: &JSONSchema{Name: "s", Schema: map[string]any{"type": "object"}}}
+
+	if _, err := p.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if body["model"] != "llama3" {
+		t.Errorf("model is %v", body["model"])
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Error("structured outputs should be off by default for compatible servers")
+	}
+
+	p.StructuredOutputs = true
+	if _, err := p.Complete(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["response_format"]; !ok {
+		t.Error("structured outputs were turned on but no response_format was sent")
+	}
+}
@@ -0,0 +1,144 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Voting policies for a judge panel.
+const (
+	PolicyUnanimous = "unanimous"
+	PolicyMajority  = "majority"
+	PolicyWeighted  = "weighted"
+)
+
+// Personas are the built-in review focuses a Juror can take.
+var Personas = map[string]string{
+	"logic":          "correctness and completeness of the business logic against the requirements.",
+	"security":       "security: authentication, authorization, secret handling, input validation and abuse cases.",
+	"ux":             "user experience: clear feedback to the user, minimal steps, recoverable mistakes.",
+	"error-handling": "failure paths: timeouts, retries, partial failures, rollbacks and what the user sees when things break.",
+}
+
+// personaPrompt expands a built-in persona name; anything else is used verbatim.
+func personaPrompt(persona string) string {
+	if p, ok := Personas[persona]; ok {
+		return p
+	}
+	return persona
+}
+
+// Juror is one member of a judge panel.
+type Juror struct {
+	Name    string  `json:"name"`
+	Persona string  `json:"persona,omitempty"` // built-in persona name or free-form focus
+	Model   string  `json:"model,omitempty"`   // overrides the Judge model
+	Weight  float64 `json:"weight,omitempty"`  // used by the weighted policy, default 1
+}
+
+// DefaultPanel is the panel used when none is configured.
+var DefaultPanel = []Juror{
+	{Name: "Logic", Persona: "logic"},
+	{Name: "Security", Persona: "security"},
+	{Name: "Error handling", Persona: "error-handling"},
+}
+
+// Vote is one juror's verdict. Err is set if the juror could not vote.
+type Vote struct {
+	Juror Juror
+	JudgeResponse
+	Err error
+}
+
+// Verdict is the aggregated result of a judge panel.
+type Verdict struct {
+	Approved bool
+	Policy   string
+	Votes    []Vote
+}
+
+// Critique combines the critiques of every rejecting juror.
+func (v Verdict) Critique() string {
+	return v.collect(func(vote Vote) string { return vote.JudgeResponse.Critique })
+}
+
+// Dissent combines the dissents of every rejecting juror.
+func (v Verdict) Dissent() string {
+	return v.collect(func(vote Vote) string { return vote.Dissent })
+}
+
+func (v Verdict) collect(field func(Vote) string) string {
+	var parts []string
+	for _, vote := range v.Votes {
+		if vote.Err != nil || vote.Approved || field(vote) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("[%s] %s", vote.Juror.Name, field(vote)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// JudgePanel runs every juror concurrently and aggregates their votes with
+// the given policy. If any juror fails, the panel fails: its errors are
+// returned along with the votes that were cast, and the verdict is not
+// approved. Counting only the jurors that answered would let a single
+// approval pass a unanimous panel whose other members timed out.
+func JudgePanel(ctx context.Context, a Agent, panel []Juror, policy string, flowchartJSON string, requirements string) (Verdict, error) {
+	if len(panel) == 0 {
+		panel = DefaultPanel
+	}
+
+	votes := make([]Vote, len(panel))
+	var wg sync.WaitGroup
+	for i, juror := range panel {
+		wg.Add(1)
+		go func(i int, juror Juror) {
+			defer wg.Done()
+			ja := a
+			if juror.Model != "" {
+				ja.Settings.Model = juror.Model
+			}
+			res, err := Judgement(ctx, ja, juror.Persona, flowchartJSON, requirements)
+			votes[i] = Vote{Juror: juror, JudgeResponse: res, Err: err}
+		}(i, juror)
+	}
+	wg.Wait()
+
+	verdict := Verdict{Policy: policy, Votes: votes}
+	var errs []error
+	var cast, approvals int
+	var totalWeight, approvedWeight float64
+	for _, v := range votes {
+		if v.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.Juror.Name, v.Err))
+			continue
+		}
+		w := v.Juror.Weight
+		if w <= 0 {
+			w = 1
+		}
+		cast++
+		totalWeight += w
+		if v.Approved {
+			approvals++
+			approvedWeight += w
+		}
+	}
+	if len(errs) > 0 {
+		return verdict, errors.Join(errs...)
+	}
+
+	switch policy {
+	case PolicyMajority:
+		verdict.Approved = approvals*2 > cast
+	case PolicyWeighted:
+		verdict.Approved = approvedWeight*2 > totalWeight
+	default:
+		verdict.Policy = PolicyUnanimous
+		verdict.Approved = approvals == cast
+	}
+	return verdict, nil
+}
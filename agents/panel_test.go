@@ -0,0 +1,154 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// jurorProvider answers each juror from its own script, picked by the
+// model the juror was given, since jurors call concurrently.
+type jurorProvider map[string]*ScriptedProvider
+
+func (p jurorProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	return p[req.Model].Complete(ctx, req)
+}
+
+const (
+	approve = `{"approved": true, "critique": "", "dissent": ""}`
+	reject  = `{"approved": false, "critique": "Missing error path.", "dissent": "Timeouts are ignored."}`
+)
+
+// panelOf returns a panel with one juror per script, named after it, and a
+// provider that answers each juror with its script.
+func panelOf(scripts map[string]*ScriptedProvider) ([]Juror, Provider) {
+	var panel []Juror
+	for name := range scripts {
+		panel = append(panel, Juror{Name: name, Model: name})
+	}
+	return panel, jurorProvider(scripts)
+}
+
+func failing(err error) *ScriptedProvider {
+	p := &ScriptedProvider{}
+	p.PushError(err)
+	return p
+}
+
+func TestJudgePanelFailsIfAnyJurorFails(t *testing.T) {
+	for _, policy := range []string{PolicyUnanimous, PolicyMajority, PolicyWeighted} {
+		t.Run(policy, func(t *testing.T) {
+			panel, p := panelOf(map[string]*ScriptedProvider{
+				"logic":    NewScriptedProvider(approve),
+				"security": failing(errors.New("timeout")),
+				"errors":   failing(errors.New("rate limited")),
+			})
+			v, err := JudgePanel(context.Background(), Agent{Name: Judge, Provider: p}, panel, policy, "{}", "reqs")
+			if err == nil {
+				t.Fatalf("got %+v, want the panel to fail", v)
+			}
+			if v.Approved {
+				t.Error("a failed panel was approved")
+			}
+			for _, want := range []string{"security: ", "timeout", "errors: ", "rate limited"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			if len(v.Votes) != 3 {
+				t.Errorf("got %d votes, want every juror recorded", len(v.Votes))
+			}
+		})
+	}
+}
+
+func TestJudgePanelPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		votes  map[string]string
+		weight map[string]float64
+		want   bool
+	}{
+		{PolicyUnanimous, map[string]string{"a": approve, "b": approve, "c": approve}, nil, true},
+		{PolicyUnanimous, map[string]string{"a": approve, "b": approve, "c": reject}, nil, false},
+		{"", map[string]string{"a": approve, "b": reject}, nil, false}, // unanimous by default
+		{PolicyMajority, map[string]string{"a": approve, "b": approve, "c": reject}, nil, true},
+		{PolicyMajority, map[string]string{"a": approve, "b": reject}, nil, false}, // a tie is not a majority
+		{PolicyWeighted, map[string]string{"a": approve, "b": reject, "c": reject}, map[string]float64{"a": 3}, true},
+		{PolicyWeighted, map[string]string{"a": approve, "b": reject, "c": reject}, map[string]float64{"a": 2}, false},
+		{PolicyWeighted, map[string]string{"a": approve, "b": reject}, map[string]float64{"a": -1}, false}, // weights default to 1
+	}
+	for _, tt := range tests {
+		scripts := map[string]*ScriptedProvider{}
+		for name, reply := range tt.votes {
+			scripts[name] = NewScriptedProvider(reply)
+		}
+		panel, p := panelOf(scripts)
+		for i := range panel {
+			panel[i].Weight = tt.weight[panel[i].Name]
+		}
+
+		v, err := JudgePanel(context.Background(), Agent{Name: Judge, Provider: p}, panel, tt.policy, "{}", "reqs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Approved != tt.want {
+			t.Errorf("%s with %v and weights %v: approved = %v, want %v", tt.policy, tt.votes, tt.weight, v.Approved, tt.want)
+		}
+		if tt.policy == "" && v.Policy != PolicyUnanimous {
+			t.Errorf("default policy is reported as %q", v.Policy)
+		}
+	}
+}
+
+func TestJudgePanelCritique(t *testing.T) {
+	panel, p := panelOf(map[string]*ScriptedProvider{
+		"a": NewScriptedProvider(approve),
+		"b": NewScriptedProvider(reject),
+	})
+	v, err := JudgePanel(context.Background(), Agent{Name: Judge, Provider: p}, panel, PolicyMajority, "{}", "reqs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Critique() != "[b] Missing error path." || v.Dissent() != "[b] Timeouts are ignored." {
+		t.Errorf("critique %q, dissent %q; want only the rejecting juror's", v.Critique(), v.Dissent())
+	}
+}
+
+func TestJudgePanelAllJurorsFail(t *testing.T) {
+	panel, p := panelOf(map[string]*ScriptedProvider{
+		"a": failing(errors.New("boom")),
+		"b": failing(errors.New("boom")),
+	})
+	v, err := JudgePanel(context.Background(), Agent{Name: Judge, Provider: p}, panel, PolicyMajority, "{}", "reqs")
+	if err == nil || v.Approved {
+		t.Fatalf("got %+v, %v; want an error", v, err)
+	}
+	for _, vote := range v.Votes {
+		if vote.Err == nil {
+			t.Errorf("juror %s has no error recorded", vote.Juror.Name)
+		}
+	}
+}
+
+func TestJudgePanelDefaults(t *testing.T) {
+	p := NewScriptedProvider(approve, approve, approve)
+	v, err := JudgePanel(context.Background(), Agent{Name: Judge, Provider: p}, nil, PolicyUnanimous, "{}", "reqs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Votes) != len(DefaultPanel) || len(p.Requests) != len(DefaultPanel) {
+		t.Errorf("got %d votes from %d calls, want the %d default jurors", len(v.Votes), len(p.Requests), len(DefaultPanel))
+	}
+	// Each juror gets its persona's focus in the system prompt.
+	for _, juror := range DefaultPanel {
+		found := false
+		for _, req := range p.Requests {
+			found = found || strings.Contains(req.Messages[0].Content, Personas[juror.Persona])
+		}
+		if !found {
+			t.Errorf("no request carried the %s persona", juror.Persona)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// PatchOp is one edit the Architect makes to an existing flowchart.
+// Fields that do not apply to an op are left empty.
+type PatchOp struct {
+	Op      string   `json:"op" enum:"add_node,update_node,remove_node,connect,disconnect,retitle,set_lanes,extract_subflow"`
+	ID      string   `json:"id"`      // add_node, update_node, remove_node, extract_subflow (the new subprocess node)
+	Type    string   `json:"type"`    // add_node, update_node (empty keeps the type)
+	Title   string   `json:"title"`   // add_node, update_node, retitle, extract_subflow (empty keeps the title)
+	Notes   string   `json:"notes"`   // add_node, update_node (empty keeps the notes)
+	Sources []string `json:"sources"` // add_node, update_node (empty keeps the sources)
+	Lane    string   `json:"lane"`    // add_node, update_node (empty keeps the lane)
+	Lanes   []string `json:"lanes"`   // set_lanes: every lane, in order
+	Nodes   []string `json:"nodes"`   // extract_subflow: the region to move into the subflow
+	Summary string   `json:"summary"` // retitle, extract_subflow (empty keeps the summary)
+	From    string   `json:"from"`    // connect, disconnect
+	To      string   `json:"to"`      // connect, disconnect
+	Branch  string   `json:"branch"`  // connect, disconnect: out, yes, no, branch or default
+
+	Label     string `json:"label"`     // connect: the branch name for "branch", else an optional caption
+	Condition string `json:"condition"` // connect: when the branch is taken, optional
+}
+
+func (o PatchOp) String() string {
+	switch o.Op {
+	case "add_node":
+		return fmt.Sprintf("add_node %s (%s) %q", o.ID, o.Type, o.Title)
+	case "update_node", "remove_node":
+		return fmt.Sprintf("%s %s", o.Op, o.ID)
+	case "connect", "disconnect":
+		branch := o.Branch
+		if o.Label != "" {
+			branch = strings.TrimSpace(branch + " " + strconv.Quote(o.Label))
+		}
+		if branch == "" {
+			return fmt.Sprintf("%s %s -> %s", o.Op, o.From, o.To)
+		}
+		return fmt.Sprintf("%s %s -> %s (%s)", o.Op, o.From, o.To, branch)
+	case "retitle":
+		return fmt.Sprintf("retitle %q", o.Title)
+	case "set_lanes":
+		return fmt.Sprintf("set_lanes %s", strings.Join(o.Lanes, ", "))
+	case "extract_subflow":
+		return fmt.Sprintf("extract_subflow %s %q (%s)", o.ID, o.Title, strings.Join(o.Nodes, ", "))
+	}
+	return o.Op
+}
+
+// Patch is the Architect's reply in edit mode.
+type Patch struct {
+	Ops []PatchOp `json:"ops"`
+}
+
+var patchSchema = SchemaFor("flowchart_patch", Patch{})
+
+// ApplyPatch applies ops to a copy of fc in order. Every op must reference
+// existing node IDs (or, for add_node, a new one) and lanes; the first op
+// that does not is reported with its position and fc is left untouched.
+func ApplyPatch(fc flow.Flowchart, ops []PatchOp) (flow.Flowchart, error) {
+	out := fc
+	out.Nodes = append([]flow.Node(nil), fc.Nodes...)
+	out.Connections = append([]flow.Connection(nil), fc.Connections...)
+	out.Lanes = append([]string(nil), fc.Lanes...)
+
+	index := func(id string) int {
+		for i, n := range out.Nodes {
+			if n.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+	hasLane := func(lane string) bool {
+		for _, l := range out.Lanes {
+			if l == lane {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i, op := range ops {
+		fail := func(format string, args ...any) (flow.Flowchart, error) {
+			return fc, fmt.Errorf("op %d (%s): %s", i+1, op.Op, fmt.Sprintf(format, args...))
+		}
+
+		switch op.Op {
+		case "add_node":
+			if op.ID == "" {
+				return fail("id is required")
+			}
+			if index(op.ID) >= 0 {
+				return fail("node %q already exists", op.ID)
+			}
+			if !isNodeType(op.Type) {
+				return fail("unknown type %q", op.Type)
+			}
+			if op.Type == "subprocess" {
+				return fail("subprocess nodes are made with extract_subflow")
+			}
+			if op.Lane != "" && !hasLane(op.Lane) {
+				return fail("unknown lane %q, add it with set_lanes first", op.Lane)
+			}
+			out.Nodes = append(out.Nodes, flow.Node{ID: op.ID, Type: op.Type, Title: op.Title, Notes: op.Notes, Lane: op.Lane, Sources: op.Sources})
+
+		case "update_node":
+			at := index(op.ID)
+			if at < 0 {
+				return fail("unknown node %q", op.ID)
+			}
+			n := &out.Nodes[at]
+			if op.Type != "" {
+				if !isNodeType(op.Type) {
+					return fail("unknown type %q", op.Type)
+				}
+				if (op.Type == "subprocess") != (n.Type == "subprocess") {
+					return fail("subprocess nodes are made with extract_subflow")
+				}
+				n.Type = op.Type
+			}
+			if op.Title != "" {
+				n.Title = op.Title
+			}
+			if op.Notes != "" {
+				n.Notes = op.Notes
+			}
+			if len(op.Sources) > 0 {
+				n.Sources = op.Sources
+			}
+			if op.Lane != "" {
+				if !hasLane(op.Lane) {
+					return fail("unknown lane %q, add it with set_lanes first", op.Lane)
+				}
+				n.Lane = op.Lane
+			}
+
+		case "remove_node":
+			at := index(op.ID)
+			if at < 0 {
+				return fail("unknown node %q", op.ID)
+			}
+			out.Nodes = append(out.Nodes[:at], out.Nodes[at+1:]...)
+			kept := out.Connections[:0]
+			for _, c := range out.Connections {
+				if c.From != op.ID && c.To != op.ID {
+					kept = append(kept, c)
+				}
+			}
+			out.Connections = kept
+
+		case "connect":
+			if index(op.From) < 0 {
+				return fail("unknown node %q", op.From)
+			}
+			if index(op.To) < 0 {
+				return fail("unknown node %q", op.To)
+			}
+			branch := op.Branch
+			if branch == "" {
+				branch = "out"
+			}
+			if !isBranchType(branch) {
+				return fail("unknown branch %q", branch)
+			}
+			for _, c := range out.Connections {
+				if c.From == op.From && c.To == op.To && c.Type == branch && c.Label == op.Label {
+					return fail("connection %s -> %s (%s) already exists", op.From, op.To, branch)
+				}
+			}
+			out.Connections = append(out.Connections, flow.Connection{From: op.From, To: op.To, Type: branch, Label: op.Label, Condition: op.Condition})
+
+		case "disconnect":
+			found := false
+			kept := out.Connections[:0]
+			for _, c := range out.Connections {
+				if c.From == op.From && c.To == op.To && (op.Branch == "" || c.Type == op.Branch) {
+					found = true
+					continue
+				}
+				kept = append(kept, c)
+			}
+			if !found {
+				return fail("no connection %s -> %s", op.From, op.To)
+			}
+			out.Connections = kept
+
+		case "retitle":
+			if op.Title == "" && op.Summary == "" {
+				return fail("title or summary is required")
+			}
+			if op.Title != "" {
+				out.Overview.Title = op.Title
+			}
+			if op.Summary != "" {
+				out.Overview.Summary = op.Summary
+			}
+
+		case "set_lanes":
+			// Nodes keep their lane; the validator reports any that no
+			// longer exists.
+			out.Lanes = append([]string(nil), op.Lanes...)
+
+		case "extract_subflow":
+			if op.ID == "" {
+				return fail("id is required")
+			}
+			if index(op.ID) >= 0 {
+				return fail("node %q already exists", op.ID)
+			}
+			if op.Title == "" {
+				return fail("title is required")
+			}
+			extracted, err := extractSubflow(out, op)
+			if err != nil {
+				return fail("%v", err)
+			}
+			out = extracted
+
+		default:
+			return fail("unknown op")
+		}
+	}
+	return out, nil
+}
+
+// extractSubflow replaces the region op.Nodes of fc with a subprocess node
+// op.ID whose embedded child flow holds the region. The region must be
+// entered through one node and left towards one node. Inside the child a
+// new start node leads to the entry, and every connection that left the
+// region ends at a new end node instead, keeping its branch.
+func extractSubflow(fc flow.Flowchart, op PatchOp) (flow.Flowchart, error) {
+	if len(op.Nodes) == 0 {
+		return fc, fmt.Errorf("nodes is required")
+	}
+	region := map[string]bool{}
+	var nodes []flow.Node
+	for _, id := range op.Nodes {
+		if region[id] {
+			continue
+		}
+		found := false
+		for _, n := range fc.Nodes {
+			if n.ID != id {
+				continue
+			}
+			if n.Type == "start" || n.Type == "end" {
+				return fc, fmt.Errorf("node %q is a %q; only steps inside the flow can be extracted", id, n.Type)
+			}
+			nodes = append(nodes, n)
+			found = true
+			break
+		}
+		if !found {
+			return fc, fmt.Errorf("unknown node %q", id)
+		}
+		region[id] = true
+	}
+
+	var entries, exits []string
+	seen := map[string]bool{}
+	for _, c := range fc.Connections {
+		switch {
+		case !region[c.From] && region[c.To] && !seen["in:"+c.To]:
+			seen["in:"+c.To] = true
+			entries = append(entries, c.To)
+		case region[c.From] && !region[c.To] && !seen["out:"+c.To]:
+			seen["out:"+c.To] = true
+			exits = append(exits, c.To)
+		}
+	}
+	if len(entries) != 1 {
+		return fc, fmt.Errorf("the region must be entered through exactly one node, but is entered through %q", entries)
+	}
+	if len(exits) != 1 {
+		return fc, fmt.Errorf("the region must lead to exactly one node outside it, but leads to %q", exits)
+	}
+	entry, exit := entries[0], exits[0]
+
+	free := func(base string) string {
+		id := base
+		for i := 2; region[id]; i++ {
+			id = fmt.Sprintf("%s%d", base, i)
+		}
+		return id
+	}
+	startID, endID := free("start"), free("end")
+	lane := func(id string) string {
+		for _, n := range nodes {
+			if n.ID == id {
+				return n.Lane
+			}
+		}
+		return ""
+	}
+
+	child := flow.Flowchart{Overview: flow.Overview{Title: op.Title, Summary: op.Summary}}
+	used := map[string]bool{}
+	for _, n := range nodes {
+		used[n.Lane] = true
+	}
+	for _, l := range fc.Lanes {
+		if used[l] {
+			child.Lanes = append(child.Lanes, l)
+		}
+	}
+	child.Nodes = append(child.Nodes, flow.Node{ID: startID, Type: "start", Title: "Start", Lane: lane(entry)})
+	child.Nodes = append(child.Nodes, nodes...)
+	endLane := ""
+	child.Connections = append(child.Connections, flow.Connection{From: startID, To: entry, Type: "out"})
+
+	out := fc
+	out.Nodes = nil
+	out.Connections = nil
+	for _, c := range fc.Connections {
+		switch {
+		case region[c.From] && region[c.To]:
+			child.Connections = append(child.Connections, c)
+		case region[c.From]:
+			if endLane == "" {
+				endLane = lane(c.From)
+			}
+			c.To = endID
+			child.Connections = append(child.Connections, c)
+		case region[c.To]:
+			c.To = op.ID
+			out.Connections = append(out.Connections, c)
+		default:
+			out.Connections = append(out.Connections, c)
+		}
+	}
+	child.Nodes = append(child.Nodes, flow.Node{ID: endID, Type: "end", Title: "End", Lane: endLane})
+	out.Connections = append(out.Connections, flow.Connection{From: op.ID, To: exit, Type: "out"})
+
+	sub := flow.Node{ID: op.ID, Type: "subprocess", Title: op.Title, Notes: op.Summary, Lane: lane(entry), Subflow: &flow.Subflow{Flow: &child}}
+	placed := false
+	for _, n := range fc.Nodes {
+		switch {
+		case !region[n.ID]:
+			out.Nodes = append(out.Nodes, n)
+		case !placed:
+			out.Nodes = append(out.Nodes, sub) // where the region was
+			placed = true
+		}
+	}
+	return out, nil
+}
+
+// EditFlowchart asks the Architect for a list of patch operations against
+// current and applies them in Go, so unrelated nodes keep their IDs. If
+// revisions is not empty, the latest rejected draft is patched instead.
+func EditFlowchart(ctx context.Context, a Agent, requirements string, research string, current flow.Flowchart, revisions []Revision) (flow.Flowchart, []PatchOp, error) {
+	sysPrompt, err := a.prompt(PromptArchitectEdit, PromptData{NodeTypes: flow.NodeTypes()})
+	if err != nil {
+		return flow.Flowchart{}, nil, err
+	}
+
+	base := current
+	if len(revisions) > 0 {
+		base = revisions[len(revisions)-1].Draft
+	}
+	base.Meta = nil
+	baseJSON, _ := json.MarshalIndent(base, "", "  ")
+
+	input := fmt.Sprintf("Requirements: %s\n\nResearch: %s\n\nFlowchart to edit:\n%s", requirements, research, string(baseJSON))
+	if len(revisions) > 0 {
+		input += "\n\nThis is your latest draft and it was rejected. Patch it to address every critique below."
+		input += formatCritiques(revisions)
+	}
+
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(input),
+	}
+
+	var patch Patch
+	var edited flow.Flowchart
+	err = a.completeJSON(ctx, messages, patchSchema, &patch, func() error {
+		if len(patch.Ops) == 0 {
+			return fmt.Errorf("no operations returned")
+		}
+		var err error
+		edited, err = ApplyPatch(base, patch.Ops)
+		return err
+	})
+	if err != nil {
+		return flow.Flowchart{}, nil, err
+	}
+	return edited, patch.Ops, nil
+}
+
+// FormatOps renders patch operations one per line for the history log.
+func FormatOps(ops []PatchOp) string {
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		lines[i] = "  " + op.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,277 @@
+package agents
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// login is a small valid flow: start -> enter -> check -> ok / denied.
+func login() flow.Flowchart {
+	return flow.Flowchart{
+		Overview: flow.Overview{Title: "Login", Summary: "Sign a user in."},
+		Lanes:    []string{"User", "Auth"},
+		Nodes: []flow.Node{
+			{ID: "start", Type: "start", Title: "Open app", Lane: "User"},
+			{ID: "enter", Type: "manual_input", Title: "Enter password", Lane: "User"},
+			{ID: "check", Type: "decision", Title: "Password correct?", Lane: "Auth"},
+			{ID: "ok", Type: "end", Title: "Signed in", Lane: "User"},
+			{ID: "denied", Type: "end", Title: "Denied", Lane: "User"},
+		},
+		Connections: []flow.Connection{
+			{From: "start", To: "enter", Type: "out"},
+			{From: "enter", To: "check", Type: "out"},
+			{From: "check", To: "ok", Type: "yes"},
+			{From: "check", To: "denied", Type: "no"},
+		},
+	}
+}
+
+func TestApplyPatchRejectsInvalidOps(t *testing.T) {
+	valid := PatchOp{Op: "retitle", Title: "Sign in"}
+	tests := []struct {
+		op   PatchOp
+		want string
+	}{
+		{PatchOp{Op: "add_node", Type: "action"}, "id is required"},
+		{PatchOp{Op: "add_node", ID: "check", Type: "action"}, `node "check" already exists`},
+		{PatchOp{Op: "add_node", ID: "n9", Type: "teleport"}, `unknown type "teleport"`},
+		{PatchOp{Op: "add_node", ID: "n9", Type: "subprocess"}, "made with extract_subflow"},
+		{PatchOp{Op: "add_node", ID: "n9", Type: "action", Lane: "Bank"}, `unknown lane "Bank"`},
+		{PatchOp{Op: "update_node", ID: "n9", Title: "x"}, `unknown node "n9"`},
+		{PatchOp{Op: "update_node", ID: "enter", Type: "teleport"}, `unknown type "teleport"`},
+		{PatchOp{Op: "update_node", ID: "enter", Type: "subprocess"}, "made with extract_subflow"},
+		{PatchOp{Op: "update_node", ID: "enter", Lane: "Bank"}, `unknown lane "Bank"`},
+		{PatchOp{Op: "remove_node", ID: "n9"}, `unknown node "n9"`},
+		{PatchOp{Op: "connect", From: "n9", To: "ok"}, `unknown node "n9"`},
+		{PatchOp{Op: "connect", From: "enter", To: "n9"}, `unknown node "n9"`},
+		{PatchOp{Op: "connect", From: "enter", To: "ok", Branch: "maybe"}, `unknown branch "maybe"`},
+		{PatchOp{Op: "connect", From: "check", To: "ok", Branch: "yes"}, "already exists"},
+		{PatchOp{Op: "disconnect", From: "start", To: "ok"}, "no connection start -> ok"},
+		{PatchOp{Op: "disconnect", From: "check", To: "ok", Branch: "no"}, "no connection check -> ok"},
+		{PatchOp{Op: "retitle"}, "title or summary is required"},
+		{PatchOp{Op: "extract_subflow", ID: "enter", Title: "x", Nodes: []string{"check"}}, `node "enter" already exists`},
+		{PatchOp{Op: "extract_subflow", ID: "sub", Nodes: []string{"check"}}, "title is required"},
+		{PatchOp{Op: "rename"}, "unknown op"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op.String(), func(t *testing.T) {
+			fc := login()
+			got, err := ApplyPatch(fc, []PatchOp{valid, tt.op})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), "op 2 ("+tt.op.Op+"): ") {
+				t.Errorf("error %q does not name the failing op", err)
+			}
+			// Neither the result nor the input carry the first op.
+			if !reflect.DeepEqual(got, login()) || !reflect.DeepEqual(fc, login()) {
+				t.Errorf("a failed patch changed the flow:\n%+v", got)
+			}
+		})
+	}
+}
+
+func TestApplyPatchNodes(t *testing.T) {
+	fc := login()
+	got, err := ApplyPatch(fc, []PatchOp{
+		{Op: "set_lanes", Lanes: []string{"User", "Auth", "Mail"}},
+		{Op: "add_node", ID: "mail", Type: "io", Title: "Send alert", Lane: "Mail", Sources: []string{"docs/alerts.md:3"}},
+		{Op: "update_node", ID: "check", Notes: "Compare bcrypt hashes"},
+		{Op: "update_node", ID: "enter", Type: "action", Lane: "Auth"},
+		{Op: "retitle", Summary: "Sign a user in, or alert on failure."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := login()
+	want.Overview.Summary = "Sign a user in, or alert on failure."
+	want.Lanes = append(want.Lanes, "Mail")
+	want.Nodes[1].Type, want.Nodes[1].Lane = "action", "Auth"
+	want.Nodes[2].Notes = "Compare bcrypt hashes" // the title is kept
+	want.Nodes = append(want.Nodes, flow.Node{ID: "mail", Type: "io", Title: "Send alert", Lane: "Mail", Sources: []string{"docs/alerts.md:3"}})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+	if !reflect.DeepEqual(fc, login()) {
+		t.Error("the input flow was modified")
+	}
+}
+
+func TestApplyPatchRemoveNode(t *testing.T) {
+	got, err := ApplyPatch(login(), []PatchOp{{Op: "remove_node", ID: "check"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range got.Nodes {
+		if n.ID == "check" {
+			t.Error("the node was not removed")
+		}
+	}
+	want := []flow.Connection{{From: "start", To: "enter", Type: "out"}}
+	if !reflect.DeepEqual(got.Connections, want) {
+		t.Errorf("connections are %+v, want only those not touching the node", got.Connections)
+	}
+}
+
+func TestApplyPatchConnections(t *testing.T) {
+	got, err := ApplyPatch(login(), []PatchOp{
+		{Op: "add_node", ID: "retry", Type: "action", Title: "Try again", Lane: "User"},
+		{Op: "connect", From: "enter", To: "retry"}, // out by default
+		{Op: "disconnect", From: "check", To: "denied", Branch: "no"},
+		{Op: "connect", From: "check", To: "retry", Branch: "branch", Label: "Locked", Condition: "attempts >= 3"},
+		{Op: "connect", From: "check", To: "denied", Branch: "default", Label: "Other"},
+		{Op: "connect", From: "retry", To: "enter", Branch: "out", Label: "again"},
+		{Op: "disconnect", From: "enter", To: "retry"}, // any branch
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []flow.Connection{
+		{From: "start", To: "enter", Type: "out"},
+		{From: "enter", To: "check", Type: "out"},
+		{From: "check", To: "ok", Type: "yes"},
+		{From: "check", To: "retry", Type: "branch", Label: "Locked", Condition: "attempts >= 3"},
+		{From: "check", To: "denied", Type: "default", Label: "Other"},
+		{From: "retry", To: "enter", Type: "out", Label: "again"},
+	}
+	if !reflect.DeepEqual(got.Connections, want) {
+		t.Errorf("connections are\n%+v\nwant\n%+v", got.Connections, want)
+	}
+
+	// The same pair may be connected twice under different labels.
+	if _, err := ApplyPatch(got, []PatchOp{{Op: "connect", From: "check", To: "retry", Branch: "branch", Label: "Expired"}}); err != nil {
+		t.Errorf("a second branch with another label was refused: %v", err)
+	}
+}
+
+// checkout is a flow whose payment step retries until it is confirmed:
+// start -> cart -> pay -> confirm -yes-> receipt -> done, confirm -no-> pay.
+func checkout() flow.Flowchart {
+	return flow.Flowchart{
+		Overview: flow.Overview{Title: "Checkout"},
+		Lanes:    []string{"User", "Shop", "Bank"},
+		Nodes: []flow.Node{
+			{ID: "start", Type: "start", Title: "Open cart", Lane: "User"},
+			{ID: "cart", Type: "action", Title: "Review cart", Lane: "User"},
+			{ID: "pay", Type: "action", Title: "Charge card", Lane: "Bank"},
+			{ID: "confirm", Type: "decision", Title: "Charged?", Lane: "Bank"},
+			{ID: "receipt", Type: "io", Title: "Email receipt", Lane: "Shop"},
+			{ID: "done", Type: "end", Title: "Done", Lane: "User"},
+		},
+		Connections: []flow.Connection{
+			{From: "start", To: "cart", Type: "out"},
+			{From: "cart", To: "pay", Type: "out"},
+			{From: "pay", To: "confirm", Type: "out"},
+			{From: "confirm", To: "receipt", Type: "yes", Label: "Paid"},
+			{From: "confirm", To: "pay", Type: "no"},
+			{From: "receipt", To: "done", Type: "out"},
+		},
+	}
+}
+
+func TestExtractSubflow(t *testing.T) {
+	got, err := ApplyPatch(checkout(), []PatchOp{{Op: "extract_subflow", ID: "payment", Title: "Payment", Summary: "Charge until paid.", Nodes: []string{"pay", "confirm"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := Validate(got); len(issues) > 0 {
+		t.Errorf("the extracted flow is invalid:\n%s", FormatIssues(issues))
+	}
+
+	// The parent has the subprocess where the region was, wired in its place.
+	var ids []string
+	for _, n := range got.Nodes {
+		ids = append(ids, n.ID)
+	}
+	if strings.Join(ids, " ") != "start cart payment receipt done" {
+		t.Errorf("parent nodes are %q", ids)
+	}
+	sub := got.Nodes[2]
+	if sub.Type != "subprocess" || sub.Title != "Payment" || sub.Notes != "Charge until paid." || sub.Lane != "Bank" || sub.Subflow == nil || sub.Subflow.Flow == nil {
+		t.Fatalf("subprocess node is %+v", sub)
+	}
+	wantParent := []flow.Connection{
+		{From: "start", To: "cart", Type: "out"},
+		{From: "cart", To: "payment", Type: "out"},
+		{From: "receipt", To: "done", Type: "out"},
+		{From: "payment", To: "receipt", Type: "out"},
+	}
+	if !reflect.DeepEqual(got.Connections, wantParent) {
+		t.Errorf("parent connections are\n%+v\nwant\n%+v", got.Connections, wantParent)
+	}
+
+	// The child starts at the entry and ends where the region was left,
+	// keeping the branch that left it.
+	child := *sub.Subflow.Flow
+	if child.Overview.Title != "Payment" || !reflect.DeepEqual(child.Lanes, []string{"Bank"}) {
+		t.Errorf("child overview %+v, lanes %q", child.Overview, child.Lanes)
+	}
+	wantNodes := []flow.Node{
+		{ID: "start", Type: "start", Title: "Start", Lane: "Bank"},
+		checkout().Nodes[2],
+		checkout().Nodes[3],
+		{ID: "end", Type: "end", Title: "End", Lane: "Bank"},
+	}
+	if !reflect.DeepEqual(child.Nodes, wantNodes) {
+		t.Errorf("child nodes are\n%+v\nwant\n%+v", child.Nodes, wantNodes)
+	}
+	wantChild := []flow.Connection{
+		{From: "start", To: "pay", Type: "out"},
+		{From: "pay", To: "confirm", Type: "out"},
+		{From: "confirm", To: "end", Type: "yes", Label: "Paid"},
+		{From: "confirm", To: "pay", Type: "no"},
+	}
+	if !reflect.DeepEqual(child.Connections, wantChild) {
+		t.Errorf("child connections are\n%+v\nwant\n%+v", child.Connections, wantChild)
+	}
+}
+
+func TestExtractSubflowAvoidsTakenIDs(t *testing.T) {
+	fc := checkout()
+	fc.Nodes[2].ID = "end" // a step that happens to be called "end"
+	for i := range fc.Connections {
+		if fc.Connections[i].From == "pay" {
+			fc.Connections[i].From = "end"
+		}
+		if fc.Connections[i].To == "pay" {
+			fc.Connections[i].To = "end"
+		}
+	}
+	got, err := ApplyPatch(fc, []PatchOp{{Op: "extract_subflow", ID: "payment", Title: "Payment", Nodes: []string{"end", "confirm"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := got.Nodes[2].Subflow.Flow
+	if last := child.Nodes[len(child.Nodes)-1]; last.ID != "end2" || last.Type != "end" {
+		t.Errorf("the generated end node is %+v, want a fresh id", last)
+	}
+	if issues := Validate(got); len(issues) > 0 {
+		t.Errorf("the extracted flow is invalid:\n%s", FormatIssues(issues))
+	}
+}
+
+func TestExtractSubflowRejectsRegions(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []string
+		want  string
+	}{
+		{"empty", nil, "nodes is required"},
+		{"unknown node", []string{"pay", "n9"}, `unknown node "n9"`},
+		{"start node", []string{"start", "cart"}, `node "start" is a "start"`},
+		{"end node", []string{"receipt", "done"}, `node "done" is a "end"`},
+		{"two entries", []string{"cart", "pay"}, "entered through exactly one node"},
+		{"two exits", []string{"confirm"}, "lead to exactly one node outside it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ApplyPatch(checkout(), []PatchOp{{Op: "extract_subflow", ID: "sub", Title: "Sub", Nodes: tt.nodes}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package agents
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// Prompt template names. Each is a file <name>.tmpl in agents/prompts and
+// can be overridden by a file of the same name in a prompt directory.
+const (
+	PromptAnalyst        = "analyst"
+	PromptResearcher     = "researcher"
+	PromptArchitect      = "architect"       // JSON mode, new flows and revisions
+	PromptArchitectEdit  = "architect_edit"  // patch operations against an existing flow
+	PromptArchitectTools = "architect_tools" // tools mode
+	PromptJudge          = "judge"           // rendered once per juror
+)
+
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+// PromptData is what every prompt template is executed with. Fields that
+// do not apply to an agent are left zero.
+type PromptData struct {
+	Agent     string // analyst, researcher, architect or judge (see Agent.Name)
+	MaxRounds int    // Analyst: rounds of questions it may ask
+	Documents bool   // Researcher: excerpts from team documents are attached
+	Focus     string // Judge: the juror's persona, expanded to a sentence
+
+	// NodeTypes is every node type, built-in and custom (Architect).
+	NodeTypes []flow.NodeType
+}
+
+// Prompts is a set of parsed system prompt templates: the built-in ones
+// with any overrides laid on top.
+type Prompts struct {
+	templates map[string]*template.Template
+	sources   map[string]string
+
+	// Overrides lists the files that replaced a built-in template.
+	Overrides []string
+}
+
+// DefaultPrompts are the built-in templates, used by agents without Prompts.
+var DefaultPrompts = mustLoadPrompts()
+
+func mustLoadPrompts() *Prompts {
+	p, err := LoadPrompts()
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadPrompts parses the built-in templates, then overrides them with the
+// *.tmpl files found in dirs, later directories winning. A file that does
+// not name a known template is an error, so a typo does not silently fall
+// back to the default.
+func LoadPrompts(dirs ...string) (*Prompts, error) {
+	p := &Prompts{templates: map[string]*template.Template{}, sources: map[string]string{}}
+	builtin, err := promptFiles.ReadDir("prompts")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range builtin {
+		data, err := promptFiles.ReadFile("prompts/" + f.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := p.parse(strings.TrimSuffix(f.Name(), ".tmpl"), string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, dir := range dirs {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("prompt directory: %w", err)
+		}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".tmpl" {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			name := strings.TrimSuffix(f.Name(), ".tmpl")
+			if _, ok := p.templates[name]; !ok {
+				return nil, fmt.Errorf("%s: unknown prompt %q, expected one of %s", path, name, strings.Join(p.Names(), ", "))
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.parse(name, string(data)); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			p.Overrides = append(p.Overrides, path)
+		}
+	}
+	return p, nil
+}
+
+func (p *Prompts) parse(name, text string) error {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return err
+	}
+	// Catch references to variables that do not exist now rather than
+	// in the middle of a run.
+	if err := t.Execute(io.Discard, PromptData{}); err != nil {
+		return err
+	}
+	p.templates[name] = t
+	p.sources[name] = text
+	return nil
+}
+
+// Names lists the template names in alphabetical order.
+func (p *Prompts) Names() []string {
+	names := make([]string, 0, len(p.templates))
+	for name := range p.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named template.
+func (p *Prompts) Render(name string, data PromptData) (string, error) {
+	t, ok := p.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt %q", name)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Hash identifies the effective set of templates, so two saved flows can
+// be told apart when they were produced with different prompts.
+func (p *Prompts) Hash() string {
+	h := sha256.New()
+	for _, name := range p.Names() {
+		fmt.Fprintf(h, "%s\x00%s\x00", name, p.sources[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Info is the summary of p that is saved in flow metadata.
+func (p *Prompts) Info() *PromptInfo {
+	return &PromptInfo{Hash: p.Hash(), Overrides: p.Overrides}
+}
+
+// PromptInfo records which prompts a flow was produced with.
+type PromptInfo struct {
+	Hash      string   `json:"hash"`
+	Overrides []string `json:"overrides,omitempty"`
+}
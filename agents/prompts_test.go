@@ -0,0 +1,127 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// promptDir writes the given templates to a fresh directory.
+func promptDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadPromptsBuiltin(t *testing.T) {
+	p, err := LoadPrompts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{PromptAnalyst, PromptArchitect, PromptArchitectEdit, PromptArchitectTools, PromptJudge, PromptResearcher}
+	if !slices.Equal(p.Names(), want) {
+		t.Errorf("names are %q, want %q", p.Names(), want)
+	}
+	if len(p.Overrides) != 0 || p.Hash() != DefaultPrompts.Hash() {
+		t.Errorf("built-in prompts have overrides %q and hash %s, want none and %s", p.Overrides, p.Hash(), DefaultPrompts.Hash())
+	}
+	s, err := p.Render(PromptJudge, PromptData{Focus: "ZZ-focus"})
+	if err != nil || !strings.Contains(s, "ZZ-focus") {
+		t.Errorf("judge prompt does not carry the focus: %v\n%s", err, s)
+	}
+}
+
+func TestLoadPromptsOverride(t *testing.T) {
+	first := promptDir(t, map[string]string{
+		"judge.tmpl":   "First judge",
+		"analyst.tmpl": "You are the {{.Agent}} and may ask {{.MaxRounds}} rounds.",
+		"README.md":    "not a template",
+	})
+	second := promptDir(t, map[string]string{"judge.tmpl": "Second judge: {{.Focus}}"})
+
+	p, err := LoadPrompts(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Agent{Name: Analyst, Prompts: p}
+	if s, err := a.prompt(PromptAnalyst, PromptData{MaxRounds: 3}); err != nil || s != "You are the analyst and may ask 3 rounds." {
+		t.Errorf("analyst prompt is %q, %v", s, err)
+	}
+	// Later directories win.
+	if s, _ := p.Render(PromptJudge, PromptData{Focus: "security"}); s != "Second judge: security" {
+		t.Errorf("judge prompt is %q", s)
+	}
+	if s, _ := p.Render(PromptResearcher, PromptData{}); s != mustRender(t, DefaultPrompts, PromptResearcher) {
+		t.Error("a template without an override changed")
+	}
+	if len(p.Overrides) != 3 || p.Overrides[2] != filepath.Join(second, "judge.tmpl") {
+		t.Errorf("overrides are %q", p.Overrides)
+	}
+}
+
+func mustRender(t *testing.T, p *Prompts, name string) string {
+	t.Helper()
+	s, err := p.Render(name, PromptData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLoadPromptsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"unknown name", map[string]string{"juge.tmpl": "typo"}, `unknown prompt "juge", expected one of analyst, architect`},
+		{"syntax error", map[string]string{"judge.tmpl": "{{.Focus"}, "judge.tmpl: template: judge"},
+		{"unknown field", map[string]string{"judge.tmpl": "{{.Persona}}"}, "can't evaluate field Persona"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPrompts(promptDir(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := LoadPrompts(filepath.Join(t.TempDir(), "missing")); err == nil || !strings.HasPrefix(err.Error(), "prompt directory: ") {
+		t.Errorf("a missing directory gave %v", err)
+	}
+	if _, err := DefaultPrompts.Render("critic", PromptData{}); err == nil {
+		t.Error("rendering an unknown template should fail")
+	}
+}
+
+func TestPromptsHash(t *testing.T) {
+	changed, err := LoadPrompts(promptDir(t, map[string]string{"judge.tmpl": "Judge harder."}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadPrompts(promptDir(t, map[string]string{"judge.tmpl": "Judge harder."}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadPrompts(promptDir(t, map[string]string{"judge.tmpl": "Judge softer."}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Hash() == DefaultPrompts.Hash() || changed.Hash() == other.Hash() {
+		t.Error("changing a template did not change the hash")
+	}
+	if changed.Hash() != again.Hash() {
+		t.Error("the same templates from another directory have a different hash")
+	}
+	info := changed.Info()
+	if info.Hash != changed.Hash() || len(info.Overrides) != 1 {
+		t.Errorf("info is %+v", info)
+	}
+}
@@ -0,0 +1,101 @@
+package agents
+
+import (
+	"context"
+)
+
+// DefaultModel is the model every agent uses unless told otherwise.
+const DefaultModel = "gpt-5-nano-2025-08-07"
+
+// Message roles understood by every Provider.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is a single chat turn. Assistant turns may carry ToolCalls;
+// tool turns answer one of them and name it in ToolCallID.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool is a function the model may call instead of answering directly.
+// Parameters is a JSON schema object, usually built with SchemaFor.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// ToolCall is one function call requested by the model. Arguments is the
+// raw JSON the model produced and must be validated before use.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Options are the optional sampling parameters of a completion.
+// Zero values mean "use the provider default".
+type Options struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxTokens       int64    `json:"max_tokens,omitempty"`
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"` // minimal, low, medium, high
+}
+
+// Request is a chat completion request.
+type Request struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Options  Options   `json:"options"`
+
+	// Schema, if set, asks for output matching a JSON schema. Providers
+	// without structured output support ignore it.
+	Schema *JSONSchema `json:"schema,omitempty"`
+
+	// Tools, if set, may be called by the model. The reply then carries
+	// ToolCalls instead of (or next to) Content.
+	Tools []Tool `json:"tools,omitempty"`
+
+	// Stream, if set, receives content deltas as they arrive. Providers that
+	// cannot stream call it once with the whole content.
+	Stream func(delta string) `json:"-"`
+}
+
+// Response is the assistant's reply to a Request.
+type Response struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     Usage      `json:"usage"`
+}
+
+// Provider is anything that can answer a chat completion request.
+// Agents only talk to LLMs through this interface.
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+}
+
+// SystemMessage is a shorthand for a system Message.
+func SystemMessage(content string) Message {
+	return Message{Role: RoleSystem, Content: content}
+}
+
+// UserMessage is a shorthand for a user Message.
+func UserMessage(content string) Message {
+	return Message{Role: RoleUser, Content: content}
+}
+
+// AssistantMessage is a shorthand for an assistant Message.
+func AssistantMessage(content string) Message {
+	return Message{Role: RoleAssistant, Content: content}
+}
+
+// ToolMessage is a shorthand for the result of the tool call with the given id.
+func ToolMessage(id, content string) Message {
+	return Message{Role: RoleTool, Content: content, ToolCallID: id}
+}
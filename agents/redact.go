@@ -0,0 +1,211 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Detector finds one kind of sensitive value in text.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Valid   func(match string) bool // optional second check, e.g. a checksum
+}
+
+// Detectors are the built-in detectors, by name.
+var Detectors = map[string]Detector{
+	"jwt":     {Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	"api_key": {Name: "api_key", Pattern: regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})`)},
+	"email":   {Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	"card":    {Name: "card", Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), Valid: luhn},
+	"ip":      {Name: "ip", Pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{1,4}\b`), Valid: validIP},
+}
+
+// DetectorNames lists the built-in detectors in the order they run. Tokens
+// come first so an email inside a JWT claim is not split off on its own.
+var DetectorNames = []string{"jwt", "api_key", "email", "card", "ip"}
+
+// luhn reports whether the digits in s pass the Luhn checksum of card numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+func validIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+// Redaction is one sensitive value seen for the first time.
+type Redaction struct {
+	Detector    string
+	Placeholder string
+}
+
+// Redactor replaces sensitive values with placeholders such as
+// "[EMAIL_1]" and restores them later. The same value always gets the same
+// placeholder, so agents can refer to it consistently across calls. It is
+// safe for concurrent use.
+type Redactor struct {
+	detectors []Detector
+
+	// OnRedact, if set, is told about every value the first time it is
+	// redacted.
+	OnRedact func(Redaction)
+
+	mu           sync.Mutex
+	placeholders map[string]string // value -> placeholder
+	values       map[string]string // placeholder -> value
+	counts       map[string]int    // per detector
+}
+
+// NewRedactor builds a Redactor from built-in detector names and custom
+// patterns (name -> regular expression). Custom patterns run after the
+// built-in detectors.
+func NewRedactor(detectors []string, patterns map[string]string) (*Redactor, error) {
+	r := &Redactor{
+		placeholders: map[string]string{},
+		values:       map[string]string{},
+		counts:       map[string]int{},
+	}
+	for _, name := range detectors {
+		d, ok := Detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q, expected one of %s", name, strings.Join(DetectorNames, ", "))
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		re, err := regexp.Compile(patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", name, err)
+		}
+		r.detectors = append(r.detectors, Detector{Name: name, Pattern: re})
+	}
+	return r, nil
+}
+
+// Redact replaces every sensitive value in text with its placeholder.
+func (r *Redactor) Redact(text string) string {
+	for _, d := range r.detectors {
+		text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.Valid != nil && !d.Valid(match) {
+				return match
+			}
+			return r.placeholder(d.Name, match)
+		})
+	}
+	return text
+}
+
+func (r *Redactor) placeholder(detector, value string) string {
+	r.mu.Lock()
+	p, ok := r.placeholders[value]
+	if !ok {
+		r.counts[detector]++
+		p = fmt.Sprintf("[%s_%d]", strings.ToUpper(detector), r.counts[detector])
+		r.placeholders[value] = p
+		r.values[p] = value
+	}
+	r.mu.Unlock()
+	if !ok && r.OnRedact != nil {
+		r.OnRedact(Redaction{Detector: detector, Placeholder: p})
+	}
+	return p
+}
+
+// replacer builds a replacer from placeholders back to values. escape, if
+// set, is applied to each value first.
+func (r *Redactor) replacer(escape func(string) string) *strings.Replacer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pairs := make([]string, 0, 2*len(r.values))
+	for p, v := range r.values {
+		if escape != nil {
+			v = escape(v)
+		}
+		pairs = append(pairs, p, v)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// Restore puts the original values back in place of their placeholders.
+// Placeholders this Redactor did not produce are left alone.
+func (r *Redactor) Restore(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.replacer(nil).Replace(text)
+}
+
+// Unredact returns a copy of v with the placeholders in every string
+// restored. v must survive a JSON round trip, as Flowchart and Metadata do.
+func Unredact[T any](r *Redactor, v T) (T, error) {
+	if r == nil {
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v, err
+	}
+	restored := r.replacer(func(value string) string {
+		quoted, _ := json.Marshal(value)
+		return string(quoted[1 : len(quoted)-1])
+	}).Replace(string(data))
+	var out T
+	if err := json.Unmarshal([]byte(restored), &out); err != nil {
+		return v, err
+	}
+	return out, nil
+}
+
+// RedactingProvider redacts every message before it is passed to Inner,
+// so sensitive values never leave the machine. Replies keep the
+// placeholders; restore them with the Redactor when the result is saved.
+type RedactingProvider struct {
+	Inner    Provider
+	Redactor *Redactor
+}
+
+// Complete implements Provider.
+func (p *RedactingProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	messages := make([]Message, len(req.Messages))
+	for i, m := range req.Messages {
+		m.Content = p.Redactor.Redact(m.Content)
+		if len(m.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(m.ToolCalls))
+			for j, c := range m.ToolCalls {
+				c.Arguments = p.Redactor.Redact(c.Arguments)
+				calls[j] = c
+			}
+			m.ToolCalls = calls
+		}
+		messages[i] = m
+	}
+	req.Messages = messages
+	return p.Inner.Complete(ctx, req)
+}
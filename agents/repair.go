@@ -0,0 +1,58 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// DefaultMaxRepairs is how often an agent may be asked to fix its own
+// output before the failure is surfaced.
+const DefaultMaxRepairs = 2
+
+// RepairError is returned when an agent's output was still unusable after
+// every repair attempt.
+type RepairError struct {
+	Agent    string
+	Attempts int
+	Err      error // the last parse or validation error
+}
+
+func (e *RepairError) Error() string {
+	return fmt.Sprintf("%s output still invalid after %d attempts: %v", e.Agent, e.Attempts, e.Err)
+}
+
+func (e *RepairError) Unwrap() error { return e.Err }
+
+// completeJSON asks the agent for JSON, decodes it into out and runs
+// validate (which may be nil). If either step fails, the error is fed back
+// to the same agent as a follow-up message, up to MaxRepairs times.
+func (a Agent) completeJSON(ctx context.Context, messages []Message, schema *JSONSchema, out any, validate func() error) error {
+	for attempt := 1; ; attempt++ {
+		res, err := a.complete(ctx, messages, schema)
+		if err != nil {
+			return err
+		}
+
+		// Start from a clean value so a failed attempt leaves nothing behind.
+		v := reflect.ValueOf(out).Elem()
+		v.Set(reflect.Zero(v.Type()))
+
+		err = decodeJSON(res.Content, out)
+		if err == nil && validate != nil {
+			err = validate()
+		}
+		if err == nil {
+			return nil
+		}
+
+		if attempt > a.MaxRepairs {
+			return &RepairError{Agent: a.Name, Attempts: attempt, Err: err}
+		}
+		a.notify(fmt.Sprintf("Output rejected (%v). Asking for a repair (%d/%d).", err, attempt, a.MaxRepairs))
+		messages = append(messages,
+			AssistantMessage(res.Content),
+			UserMessage(fmt.Sprintf("Your previous reply could not be used: %v\nFix the problem and return the complete, corrected JSON only.", err)),
+		)
+	}
+}
@@ -0,0 +1,125 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type counted struct {
+	N     int    `json:"n"`
+	Label string `json:"label"`
+}
+
+// positive is a validator for counted that rejects n <= 0.
+func positive(v *counted) func() error {
+	return func() error {
+		if v.N <= 0 {
+			return fmt.Errorf("n must be positive, got %d", v.N)
+		}
+		return nil
+	}
+}
+
+func repairAgent(p Provider, notes *[]string) Agent {
+	return Agent{Name: Architect, Provider: p, MaxRepairs: 2, Notify: func(s string) { *notes = append(*notes, s) }}
+}
+
+func TestRepairInvalidJSON(t *testing.T) {
+	p := NewScriptedProvider("Sure! Here it is: {n: 1", `{"n": 1, "label": "ok"}`)
+	var notes []string
+	var v counted
+	err := repairAgent(p, &notes).completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, positive(&v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (counted{N: 1, Label: "ok"}) {
+		t.Errorf("decoded %+v", v)
+	}
+	if len(p.Requests) != 2 || len(notes) != 1 {
+		t.Fatalf("made %d calls with %d notes, want 2 and 1", len(p.Requests), len(notes))
+	}
+	// The bad reply and the reason it failed are sent back.
+	msgs := p.Requests[1].Messages
+	if len(msgs) != 3 || msgs[1].Role != RoleAssistant || msgs[1].Content != "Sure! Here it is: {n: 1" {
+		t.Fatalf("repair request is %+v", msgs)
+	}
+	if last := msgs[2]; last.Role != RoleUser || !strings.Contains(last.Content, "could not be used") {
+		t.Errorf("repair prompt is %q", last.Content)
+	}
+}
+
+func TestRepairValidationError(t *testing.T) {
+	p := NewScriptedProvider(`{"n": 0, "label": "stale"}`, `{"n": 3}`)
+	var notes []string
+	var v counted
+	if err := repairAgent(p, &notes).completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, positive(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if v != (counted{N: 3}) {
+		t.Errorf("decoded %+v, want nothing left over from the rejected reply", v)
+	}
+	if last := p.Requests[1].Messages[2].Content; !strings.Contains(last, "n must be positive, got 0") {
+		t.Errorf("repair prompt does not carry the validation error: %q", last)
+	}
+}
+
+func TestRepairGivesUp(t *testing.T) {
+	p := NewScriptedProvider("no", "still no", `{"n": -1}`, `{"n": 5}`)
+	var notes []string
+	var v counted
+	err := repairAgent(p, &notes).completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, positive(&v))
+
+	var repairErr *RepairError
+	if !errors.As(err, &repairErr) {
+		t.Fatalf("got %v, want a RepairError", err)
+	}
+	if repairErr.Agent != Architect || repairErr.Attempts != 3 || !strings.Contains(repairErr.Err.Error(), "n must be positive") {
+		t.Errorf("error is %+v", repairErr)
+	}
+	if len(p.Requests) != 3 || len(notes) != 2 {
+		t.Errorf("made %d calls with %d notes, want 3 and 2 (MaxRepairs)", len(p.Requests), len(notes))
+	}
+	// The conversation grows by one reply and one complaint per repair.
+	if n := len(p.Requests[2].Messages); n != 5 {
+		t.Errorf("the last repair request has %d messages, want 5", n)
+	}
+}
+
+func TestRepairDisabled(t *testing.T) {
+	p := NewScriptedProvider("no", `{"n": 1}`)
+	var v counted
+	err := Agent{Name: Judge, Provider: p}.completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, nil)
+	var repairErr *RepairError
+	if !errors.As(err, &repairErr) || repairErr.Attempts != 1 || len(p.Requests) != 1 {
+		t.Errorf("got %v after %d calls, want a RepairError after one", err, len(p.Requests))
+	}
+}
+
+func TestRepairProviderErrorIsNotRepaired(t *testing.T) {
+	p := &ScriptedProvider{}
+	p.PushError(errors.New("connection reset"))
+	var notes []string
+	var v counted
+	err := repairAgent(p, &notes).completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, nil)
+	var repairErr *RepairError
+	if err == nil || errors.As(err, &repairErr) || len(p.Requests) != 1 {
+		t.Errorf("got %v after %d calls, want the provider error as is", err, len(p.Requests))
+	}
+}
+
+func TestRepairRestartsProgress(t *testing.T) {
+	p := NewScriptedProvider("bad\nreply", `{"n": 1}`)
+	var partials []string
+	a := Agent{Name: Architect, Provider: p, MaxRepairs: 1, Progress: func(s string) { partials = append(partials, s) }}
+	var v counted
+	if err := a.completeJSON(context.Background(), []Message{UserMessage("count")}, nil, &v, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", "bad\n", "bad\nreply", "", `{"n": 1}`}
+	if strings.Join(partials, "|") != strings.Join(want, "|") {
+		t.Errorf("progress was %q, want %q", partials, want)
+	}
+}
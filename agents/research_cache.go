@@ -0,0 +1,122 @@
+package agents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CachedResearch is a research report saved for reuse.
+type CachedResearch struct {
+	Key          string       `json:"key"`
+	Topic        string       `json:"topic"`
+	Model        string       `json:"model"`
+	Requirements Requirements `json:"requirements"` // what the report was written for, used on refresh
+	Report       string       `json:"report"`
+	Created      time.Time    `json:"created"`
+}
+
+// Ref is the reference to this entry that is saved in flow metadata.
+func (r CachedResearch) Ref() *ResearchRef {
+	return &ResearchRef{Key: r.Key, Topic: r.Topic, Model: r.Model}
+}
+
+// ResearchRef points a saved flow at the cached research it was built from.
+type ResearchRef struct {
+	Key   string `json:"key"`
+	Topic string `json:"topic"`
+	Model string `json:"model"`
+}
+
+// NormalizeTopic lowercases a topic, drops punctuation and collapses
+// whitespace, so "User login." and "user  login" share a cache entry.
+func NormalizeTopic(topic string) string {
+	clean := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, topic)
+	return strings.Join(strings.Fields(clean), " ")
+}
+
+// ResearchKey identifies the research for a topic written by a model.
+func ResearchKey(topic, model string) string {
+	sum := sha256.Sum256([]byte(NormalizeTopic(topic) + "\x00" + model))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ResearchCache stores research reports as JSON files in Dir, one per key.
+type ResearchCache struct {
+	Dir string
+}
+
+func (c ResearchCache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// Get loads the entry with the given key. A missing entry is reported as
+// an error satisfying errors.Is(err, os.ErrNotExist).
+func (c ResearchCache) Get(key string) (CachedResearch, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return CachedResearch{}, err
+	}
+	var r CachedResearch
+	if err := json.Unmarshal(data, &r); err != nil {
+		return CachedResearch{}, fmt.Errorf("research cache %s: %w", key, err)
+	}
+	return r, nil
+}
+
+// Put saves r, filling in its key and creation time, and returns it.
+func (c ResearchCache) Put(r CachedResearch) (CachedResearch, error) {
+	r.Key = ResearchKey(r.Topic, r.Model)
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return r, err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return r, err
+	}
+	return r, os.WriteFile(c.path(r.Key), data, 0o644)
+}
+
+// Delete removes the entry with the given key.
+func (c ResearchCache) Delete(key string) error {
+	return os.Remove(c.path(key))
+}
+
+// List returns every entry, newest first. Unreadable files are skipped.
+func (c ResearchCache) List() ([]CachedResearch, error) {
+	files, err := os.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []CachedResearch
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		r, err := c.Get(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, r)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.After(entries[j].Created) })
+	return entries, nil
+}
@@ -0,0 +1,145 @@
+package agents
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTopic(t *testing.T) {
+	for in, want := range map[string]string{
+		"User login.":        "user login",
+		"  user\tlogin  ":    "user login",
+		"OAuth2: PKCE-flow!": "oauth2 pkce flow",
+		"Überweisung (SEPA)": "überweisung sepa",
+		"":                   "",
+	} {
+		if got := NormalizeTopic(in); got != want {
+			t.Errorf("NormalizeTopic(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if ResearchKey("User login.", "gpt-5") != ResearchKey("user  login", "gpt-5") {
+		t.Error("topics that normalize alike have different keys")
+	}
+	if ResearchKey("user login", "gpt-5") == ResearchKey("user login", "gpt-5-mini") {
+		t.Error("research by different models shares a key")
+	}
+}
+
+func TestResearchCacheRoundTrip(t *testing.T) {
+	c := ResearchCache{Dir: filepath.Join(t.TempDir(), "research")} // created on first Put
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	in := CachedResearch{
+		Topic:        "User login",
+		Model:        "gpt-5",
+		Requirements: Requirements{Summary: "Sign in", Actors: []string{"User"}},
+		Report:       "## Patterns\n- Lock after 5 attempts [docs/auth.md:3]",
+		Created:      created,
+	}
+	put, err := c.Put(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if put.Key != ResearchKey("User login", "gpt-5") || !put.Created.Equal(created) {
+		t.Errorf("Put returned key %q and time %s", put.Key, put.Created)
+	}
+	got, err := c.Get(put.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, put) {
+		t.Errorf("got  %+v\nwant %+v", got, put)
+	}
+	if *got.Ref() != (ResearchRef{Key: put.Key, Topic: "User login", Model: "gpt-5"}) {
+		t.Errorf("ref is %+v", got.Ref())
+	}
+
+	// Putting the same topic again replaces the entry.
+	in.Topic, in.Report, in.Created = "user login.", "Newer", time.Time{}
+	again, err := c.Put(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Key != put.Key || again.Created.IsZero() {
+		t.Errorf("second Put returned %+v", again)
+	}
+	if entries, _ := c.List(); len(entries) != 1 || entries[0].Report != "Newer" {
+		t.Errorf("entries after a second Put: %+v", entries)
+	}
+
+	if err := c.Delete(put.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(put.Key); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get after Delete gave %v, want a not-exist error", err)
+	}
+}
+
+func TestResearchCacheMissingDir(t *testing.T) {
+	c := ResearchCache{Dir: filepath.Join(t.TempDir(), "nope")}
+	if entries, err := c.List(); err != nil || entries != nil {
+		t.Errorf("List of a missing directory gave %v, %v", entries, err)
+	}
+	if _, err := c.Get("abc"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get from a missing directory gave %v, want a not-exist error", err)
+	}
+}
+
+func TestResearchCacheCorruptEntry(t *testing.T) {
+	c := ResearchCache{Dir: t.TempDir()}
+	good, err := c.Put(CachedResearch{Topic: "login", Model: "m", Report: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"broken.json": "{not json",
+		"notes.txt":   "not an entry",
+	} {
+		if err := os.WriteFile(filepath.Join(c.Dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(c.Dir, "dir.json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Get("broken")
+	if err == nil || errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), "research cache broken") {
+		t.Errorf("Get of a corrupt entry gave %v", err)
+	}
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Key != good.Key {
+		t.Errorf("List gave %+v, want only the readable entry", entries)
+	}
+}
+
+func TestResearchCacheListOrder(t *testing.T) {
+	c := ResearchCache{Dir: t.TempDir()}
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, e := range []struct {
+		topic string
+		days  int
+	}{{"middle", 1}, {"oldest", 0}, {"newest", 2}} {
+		if _, err := c.Put(CachedResearch{Topic: e.topic, Model: "m", Created: day.AddDate(0, 0, e.days)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var topics []string
+	for _, e := range entries {
+		topics = append(topics, e.Topic)
+	}
+	if strings.Join(topics, " ") != "newest middle oldest" {
+		t.Errorf("List order is %q, want newest first", topics)
+	}
+}
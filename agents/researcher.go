@@ -3,33 +3,49 @@ package agents
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/openai/openai-go/v3"
+	"github.com/DN-OpenSource/nodey/retrieval"
 )
 
-// Research simulates a web search or knowledge expansion step.
-func Research(client *openai.Client, topic string, history []string) (string, error) {
-	// In a real app, this would call a search API.
-	// Here, we use the LLM to "hallucinate" accurate info based on its training data,
-	// formatted as if it found search results.
+// Research writes the research report the Architect works from. reqs is
+// the Analyst's requirements document and focuses the report on what
+// matters. passages are excerpts from the team's own documents; the report
+// cites them by tag (e.g. [docs/auth.md:12]) and ends with the list of
+// sources it actually used.
+func Research(ctx context.Context, a Agent, topic string, reqs Requirements, passages []retrieval.Passage) (string, error) {
+	sysPrompt, err := a.prompt(PromptResearcher, PromptData{Documents: len(passages) > 0})
+	if err != nil {
+		return "", err
+	}
 
-	sysPrompt := `You are an expert Researcher. 
-The user needs detailed information about a topic to build a flowchart.
-Provide a comprehensive summary of the steps, edge cases, and best practices for the requested process.
-Format it as a clear research report.`
+	input := fmt.Sprintf("Research Topic: %s\n\nRequirements:\n%s", topic, reqs)
+	if len(passages) > 0 {
+		input += "\n\nExcerpts:"
+		for _, p := range passages {
+			input += fmt.Sprintf("\n\n[%s]\n%s", p.Citation(), p.Text)
+		}
+	}
 
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(sysPrompt),
-		openai.UserMessage(fmt.Sprintf("Research Topic: %s", topic)),
+	messages := []Message{
+		SystemMessage(sysPrompt),
+		UserMessage(input),
 	}
 
-	res, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: messages,
-		Model:    openai.ChatModelGPT5Nano2025_08_07,
-	})
+	res, err := a.complete(ctx, messages, nil)
 	if err != nil {
 		return "", err
 	}
 
-	return res.Choices[0].Message.Content, nil
+	report := res.Content
+	var cited []string
+	for _, p := range passages {
+		if tag := "[" + p.Citation() + "]"; strings.Contains(report, tag) {
+			cited = append(cited, "- "+tag)
+		}
+	}
+	if len(cited) > 0 {
+		report = strings.TrimRight(report, "\n") + "\n\n## Sources\n" + strings.Join(cited, "\n") + "\n"
+	}
+	return report, nil
 }
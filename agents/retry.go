@@ -0,0 +1,135 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is a failed HTTP call to a model server. Providers return it
+// so the retry policy can tell transient failures from permanent ones.
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // from the Retry-After header, zero if absent
+	Err        error
+}
+
+func (e *APIError) Error() string { return e.Err.Error() }
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// RetryPolicy decides how often and how long an agent waits before
+// repeating a failed call.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; 1 or less disables retries
+	BaseDelay   time.Duration // backoff before the second attempt, doubled for every further one
+	MaxDelay    time.Duration // cap for the backoff and for Retry-After
+}
+
+// DefaultRetryPolicy is used when none is configured.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// Backoff is the wait before attempt+1: exponential in attempt with equal
+// jitter, so the result lies between half and all of the nominal delay.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Delay is the wait before attempt+1 after err. A Retry-After hint from
+// the server wins over the backoff when it is longer, up to MaxDelay.
+func (p RetryPolicy) Delay(attempt int, err error) time.Duration {
+	d := p.Backoff(attempt)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+		if d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+	}
+	return d
+}
+
+// Retryable reports whether err is worth another attempt: rate limits,
+// server errors, dropped connections and timeouts are; everything else is
+// not. A deadline is taken to be the per-attempt Timeout, so callers must
+// check that their own context is still live before retrying, as
+// Agent.send does.
+func Retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RetryEvent describes a failed attempt that is about to be repeated.
+type RetryEvent struct {
+	Attempt     int // the attempt that failed, 1-based
+	MaxAttempts int
+	Wait        time.Duration
+	Err         error
+}
+
+func (e RetryEvent) String() string {
+	reason := e.Err.Error()
+	var apiErr *APIError
+	if errors.As(e.Err, &apiErr) {
+		reason = fmt.Sprintf("%d %s", apiErr.StatusCode, http.StatusText(apiErr.StatusCode))
+	}
+	return fmt.Sprintf("%s. Retrying in %s (attempt %d/%d)", reason, e.Wait.Round(100*time.Millisecond), e.Attempt+1, e.MaxAttempts)
+}
+
+// ParseRetryAfter reads the Retry-After-Ms or Retry-After header of a
+// response. Retry-After may be a number of seconds or an HTTP date.
+func ParseRetryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil && s > 0 {
+		return time.Duration(s * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,142 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, nominal := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second, // capped
+		70: time.Second, // the shift overflows
+	} {
+		for i := 0; i < 20; i++ {
+			if d := p.Backoff(attempt); d < nominal/2 || d > nominal {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", attempt, d, nominal/2, nominal)
+			}
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}
+	if d := p.Delay(1, &APIError{StatusCode: 429, RetryAfter: 500 * time.Millisecond, Err: errors.New("429")}); d != 500*time.Millisecond {
+		t.Errorf("a longer Retry-After gave %s, want 500ms", d)
+	}
+	if d := p.Delay(1, &APIError{StatusCode: 429, RetryAfter: time.Hour, Err: errors.New("429")}); d != time.Second {
+		t.Errorf("Retry-After beyond MaxDelay gave %s, want the 1s cap", d)
+	}
+	if d := p.Delay(1, errors.New("boom")); d > 10*time.Millisecond {
+		t.Errorf("a plain error gave %s, want the backoff", d)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	api := func(status int) error { return &APIError{StatusCode: status, Err: fmt.Errorf("%d", status)} }
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{api(429), true},
+		{api(408), true},
+		{api(500), true},
+		{api(503), true},
+		{api(400), false},
+		{api(401), false},
+		{api(404), false},
+		{context.Canceled, false},
+		{fmt.Errorf("post: %w", context.Canceled), false},
+		{context.DeadlineExceeded, true}, // the per-attempt timeout
+		{fmt.Errorf("post: %w", context.DeadlineExceeded), true},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{fmt.Errorf("reading: %w", io.ErrUnexpectedEOF), true},
+		{errors.New("invalid JSON"), false},
+	} {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, tt := range []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{}, 0},
+		{http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{http.Header{"Retry-After": {"0.5"}}, 500 * time.Millisecond},
+		{http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"9"}}, 250 * time.Millisecond},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{"Retry-After": {time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}}, 0},
+	} {
+		if got := ParseRetryAfter(tt.header); got != tt.want {
+			t.Errorf("ParseRetryAfter(%v) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+	future := http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}
+	if got := ParseRetryAfter(future); got < 58*time.Second || got > time.Minute {
+		t.Errorf("an HTTP date a minute ahead gave %s", got)
+	}
+}
+
+// slowProvider waits for the call's context to end on its first slow
+// calls and answers the rest at once.
+type slowProvider struct {
+	slow  int32
+	calls atomic.Int32
+}
+
+func (p *slowProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	if p.calls.Add(1) <= p.slow {
+		<-ctx.Done()
+		return Response{}, ctx.Err()
+	}
+	return Response{Content: "ok"}, nil
+}
+
+func TestAttemptTimeoutIsRetried(t *testing.T) {
+	p := &slowProvider{slow: 1}
+	var events []RetryEvent
+	a := testAgent(p, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, &events)
+	a.Timeout = 20 * time.Millisecond
+
+	res, err := a.send(context.Background(), Request{Messages: []Message{UserMessage("hi")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "ok" || p.calls.Load() != 2 {
+		t.Errorf("got %q after %d calls, want ok after 2", res.Content, p.calls.Load())
+	}
+	if len(events) != 1 || !errors.Is(events[0].Err, context.DeadlineExceeded) {
+		t.Errorf("retry events %+v, want one for the timeout", events)
+	}
+}
+
+func TestParentDeadlineIsNotRetried(t *testing.T) {
+	p := &slowProvider{slow: 5}
+	var events []RetryEvent
+	a := testAgent(p, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, &events)
+	a.Timeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := a.send(ctx, Request{Messages: []Message{UserMessage("hi")}}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the deadline", err)
+	}
+	if p.calls.Load() != 1 || len(events) != 0 {
+		t.Errorf("made %d calls and %d retries once the run's deadline passed, want 1 and none", p.calls.Load(), len(events))
+	}
+}
@@ -0,0 +1,90 @@
+package agents
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// JSONSchema asks the provider to constrain its output to a schema.
+type JSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+// Enums are the run-time enum lists an `enum:"@name"` tag can refer to.
+var Enums = map[string]func() []string{
+	"node_types": flow.NodeTypeNames, // built-in and custom node types
+}
+
+// SchemaFor derives a strict JSON schema from the Go type of v.
+//
+// Every field becomes a required property and objects reject unknown keys,
+// which is what OpenAI's strict structured outputs demand. Fields tagged
+// `schema:"-"` are left out and an `enum:"a,b"` tag restricts string values.
+// An `enum:"@name"` tag takes its values from Enums when the schema is
+// built, for lists only known at run time.
+func SchemaFor(name string, v any) *JSONSchema {
+	return &JSONSchema{Name: name, Schema: schemaOf(reflect.TypeOf(v))}
+}
+
+func schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		addStructFields(t, props, &required)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]any{}
+}
+
+func addStructFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("schema") == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			addStructFields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaOf(f.Type)
+		if enum := f.Tag.Get("enum"); strings.HasPrefix(enum, "@") {
+			prop["enum"] = Enums[enum[1:]]()
+		} else if enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		props[name] = prop
+		*required = append(*required, name)
+	}
+}
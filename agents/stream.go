@@ -0,0 +1,64 @@
+package agents
+
+// CountElements counts the complete node and connection objects in a
+// partial, still-streaming flowchart reply. It never fails: anything that
+// is not yet a closed object inside the top-level "nodes" or "connections"
+// is ignored, and so are the elements of flows nested deeper, such as
+// embedded subflows.
+func CountElements(partial string) (nodes, connections int) {
+	type frame struct {
+		key   string // the key this container is the value of
+		array bool
+	}
+	var stack []frame
+	var lastString, pendingKey string
+	inString, escaped := false, false
+	var str []byte
+
+	for i := 0; i < len(partial); i++ {
+		c := partial[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				str = append(str, c)
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				lastString = string(str)
+			default:
+				str = append(str, c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString, str = true, str[:0]
+		case ':':
+			pendingKey = lastString
+		case ',':
+			pendingKey = ""
+		case '{', '[':
+			stack = append(stack, frame{key: pendingKey, array: c == '['})
+			pendingKey = ""
+		case '}', ']':
+			if len(stack) == 0 {
+				continue
+			}
+			stack = stack[:len(stack)-1]
+			// An element is an object directly inside an array that is a
+			// value of the outermost object.
+			if c == '}' && len(stack) == 2 && !stack[0].array && stack[1].array {
+				switch stack[1].key {
+				case "nodes":
+					nodes++
+				case "connections":
+					connections++
+				}
+			}
+		}
+	}
+	return nodes, connections
+}
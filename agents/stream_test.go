@@ -0,0 +1,44 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountElements(t *testing.T) {
+	full := `{"overview": {"title": "T", "summary": "S"},
+		"nodes": [
+			{"id": "n1", "type": "start", "title": "Begin {here}", "notes": "say \"nodes\": [{}]"},
+			{"id": "n2", "type": "subprocess", "title": "Pay", "notes": "",
+				"subflow": {"flow": {"nodes": [{"id": "c1"}, {"id": "c2"}], "connections": [{"from": "c1", "to": "c2"}]}}},
+			{"id": "n3", "type": "end", "title": "Done", "notes": ""}
+		],
+		"connections": [
+			{"from": "n1", "to": "n2", "type": "out"},
+			{"from": "n2", "to": "n3", "type": "out"}
+		]}`
+	upTo := func(marker string) string {
+		return full[:strings.Index(full, marker)+len(marker)]
+	}
+	tests := []struct {
+		name               string
+		partial            string
+		nodes, connections int
+	}{
+		{"empty", "", 0, 0},
+		{"complete", full, 3, 2},
+		{"fenced", "```json\n" + full + "\n```", 3, 2},
+		{"first node open", upTo(`{"id": "n1"`), 0, 0},
+		{"inside a subflow", upTo(`"connections": [{"from": "c1", "to": "c2"}]}}`), 1, 0},
+		{"nodes nested in another key", `{"overview": {"nodes": [{"id": "x"}]}, "nodes": [{"id": "n1"}]}`, 1, 0},
+		{"top-level array", `[{"nodes": [{"id": "x"}]}]`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, connections := CountElements(tt.partial)
+			if nodes != tt.nodes || connections != tt.connections {
+				t.Errorf("got %d nodes and %d connections, want %d and %d", nodes, connections, tt.nodes, tt.connections)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Usage is the token count of one completion as reported by the provider.
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Price is what a model costs in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// DefaultPrices are list prices of common OpenAI models. They can be
+// overridden and extended with the "prices" setting.
+var DefaultPrices = map[string]Price{
+	"gpt-5":        {Input: 1.25, Output: 10},
+	"gpt-5-mini":   {Input: 0.25, Output: 2},
+	"gpt-5-nano":   {Input: 0.05, Output: 0.40},
+	"gpt-4.1":      {Input: 2, Output: 8},
+	"gpt-4.1-mini": {Input: 0.40, Output: 1.60},
+	"gpt-4o":       {Input: 2.50, Output: 10},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.60},
+}
+
+// PriceFor looks model up in prices, then in DefaultPrices. A dated
+// snapshot such as "gpt-5-nano-2025-08-07" uses the price of the longest
+// known name it starts with.
+func PriceFor(model string, prices map[string]Price) (Price, bool) {
+	for _, table := range []map[string]Price{prices, DefaultPrices} {
+		best := ""
+		for name := range table {
+			if strings.HasPrefix(model, name) && len(name) > len(best) {
+				best = name
+			}
+		}
+		if best != "" {
+			return table[best], true
+		}
+	}
+	return Price{}, false
+}
+
+// AgentUsage is the accumulated usage of one agent, or of a whole session.
+type AgentUsage struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	Unpriced         int     `json:"unpriced_calls,omitempty"` // calls to models without a known price
+}
+
+// Tokens is the total number of tokens used.
+func (a AgentUsage) Tokens() int64 {
+	return a.PromptTokens + a.CompletionTokens
+}
+
+func (a *AgentUsage) add(o AgentUsage) {
+	a.Calls += o.Calls
+	a.PromptTokens += o.PromptTokens
+	a.CompletionTokens += o.CompletionTokens
+	a.CostUSD += o.CostUSD
+	a.Unpriced += o.Unpriced
+}
+
+// String renders the usage compactly, e.g. "12.3k tokens $0.0042".
+func (a AgentUsage) String() string {
+	cost := fmt.Sprintf("$%.4f", a.CostUSD)
+	if a.Unpriced > 0 {
+		cost += "+"
+	}
+	return fmt.Sprintf("%s tokens %s", formatTokens(a.Tokens()), cost)
+}
+
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return fmt.Sprint(n)
+}
+
+// Ledger accumulates usage per agent.
+type Ledger struct {
+	Agents map[string]AgentUsage `json:"agents"`
+	Total  AgentUsage            `json:"total"`
+}
+
+// Add records one completion by agent on model.
+func (l *Ledger) Add(agent, model string, u Usage, prices map[string]Price) {
+	entry := AgentUsage{Calls: 1, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+	if p, ok := PriceFor(model, prices); ok {
+		entry.CostUSD = (float64(u.PromptTokens)*p.Input + float64(u.CompletionTokens)*p.Output) / 1_000_000
+	} else {
+		entry.Unpriced = 1
+	}
+	if l.Agents == nil {
+		l.Agents = map[string]AgentUsage{}
+	}
+	a := l.Agents[agent]
+	a.add(entry)
+	l.Agents[agent] = a
+	l.Total.add(entry)
+}
+
+// Breakdown lists the usage of every agent on one line each.
+func (l Ledger) Breakdown() string {
+	names := make([]string, 0, len(l.Agents))
+	for name := range l.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		a := l.Agents[name]
+		lines[i] = fmt.Sprintf("  %-10s %d calls, %s", name, a.Calls, a)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Budget caps what a single run may spend. Zero fields are unlimited.
+type Budget struct {
+	Tokens int64   `json:"tokens,omitempty"`
+	USD    float64 `json:"usd,omitempty"`
+}
+
+// Exceeded reports whether u is over the budget, and why.
+func (b Budget) Exceeded(u AgentUsage) (string, bool) {
+	if b.Tokens > 0 && u.Tokens() > b.Tokens {
+		return fmt.Sprintf("%d of %d tokens used", u.Tokens(), b.Tokens), true
+	}
+	if b.USD > 0 && u.CostUSD > b.USD {
+		return fmt.Sprintf("$%.4f of $%.4f spent", u.CostUSD, b.USD), true
+	}
+	return "", false
+}
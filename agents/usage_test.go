@@ -0,0 +1,85 @@
+package agents
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPriceFor(t *testing.T) {
+	custom := map[string]Price{
+		"gpt-5":       {Input: 1, Output: 1},
+		"local-llama": {Input: 0, Output: 0},
+	}
+	tests := []struct {
+		model  string
+		prices map[string]Price
+		want   Price
+		ok     bool
+	}{
+		{"gpt-5", nil, DefaultPrices["gpt-5"], true},
+		{"gpt-5-nano-2025-08-07", nil, DefaultPrices["gpt-5-nano"], true}, // longest prefix wins over gpt-5
+		{"gpt-4o-mini-2024-07-18", nil, DefaultPrices["gpt-4o-mini"], true},
+		{"gpt-5", custom, Price{Input: 1, Output: 1}, true},      // overrides win
+		{"gpt-5-mini", custom, Price{Input: 1, Output: 1}, true}, // even over a longer default
+		{"local-llama-3", custom, Price{}, true},
+		{"claude-like", custom, Price{}, false},
+		{"", nil, Price{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := PriceFor(tt.model, tt.prices)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("PriceFor(%q) = %+v, %v; want %+v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLedger(t *testing.T) {
+	var l Ledger
+	l.Add(Analyst, "gpt-5", Usage{PromptTokens: 1_000_000, CompletionTokens: 100_000}, nil)
+	l.Add(Analyst, "gpt-5-mini", Usage{PromptTokens: 2000, CompletionTokens: 1000}, nil)
+	l.Add(Judge, "mystery", Usage{PromptTokens: 500, CompletionTokens: 500}, nil)
+
+	analyst := l.Agents[Analyst]
+	if analyst.Calls != 2 || analyst.PromptTokens != 1_002_000 || analyst.CompletionTokens != 101_000 {
+		t.Errorf("analyst usage is %+v", analyst)
+	}
+	// 1M * $1.25 + 100k * $10 per M, then 2k * $0.25 + 1k * $2 per M.
+	if want := 1.25 + 1 + 0.0005 + 0.002; math.Abs(analyst.CostUSD-want) > 1e-9 {
+		t.Errorf("analyst cost is %v, want %v", analyst.CostUSD, want)
+	}
+	if j := l.Agents[Judge]; j.Unpriced != 1 || j.CostUSD != 0 {
+		t.Errorf("unpriced judge usage is %+v", j)
+	}
+	if l.Total.Calls != 3 || l.Total.Tokens() != 1_104_000 || l.Total.Unpriced != 1 || l.Total.CostUSD != analyst.CostUSD {
+		t.Errorf("total is %+v", l.Total)
+	}
+	if got := l.Total.String(); got != "1.1M tokens $2.2525+" {
+		t.Errorf("total renders as %q", got)
+	}
+	if lines := strings.Split(l.Breakdown(), "\n"); len(lines) != 2 || !strings.Contains(lines[0], Analyst) {
+		t.Errorf("breakdown is %q, want one line per agent in order", lines)
+	}
+}
+
+func TestBudgetExceeded(t *testing.T) {
+	used := AgentUsage{PromptTokens: 800, CompletionTokens: 200, CostUSD: 0.5}
+	tests := []struct {
+		budget Budget
+		over   bool
+		reason string
+	}{
+		{Budget{}, false, ""},
+		{Budget{Tokens: 1000}, false, ""}, // exactly at the limit is fine
+		{Budget{Tokens: 999}, true, "1000 of 999 tokens used"},
+		{Budget{USD: 0.5}, false, ""},
+		{Budget{USD: 0.25}, true, "$0.5000 of $0.2500 spent"},
+		{Budget{Tokens: 10, USD: 0.25}, true, "1000 of 10 tokens used"}, // tokens are checked first
+	}
+	for _, tt := range tests {
+		reason, over := tt.budget.Exceeded(used)
+		if over != tt.over || reason != tt.reason {
+			t.Errorf("%+v: got %q, %v; want %q, %v", tt.budget, reason, over, tt.reason, tt.over)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package agents
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -12,3 +14,96 @@ func cleanJSON(s string) string {
 	s = strings.TrimSuffix(s, "```")
 	return strings.TrimSpace(s)
 }
+
+// decodeJSON unmarshals an LLM reply into v. Strict JSON is tried first;
+// if that fails, the reply is run through extractJSON to salvage the
+// first object from prose, comments and trailing commas.
+func decodeJSON(content string, v any) error {
+	err := json.Unmarshal([]byte(cleanJSON(content)), v)
+	if err == nil {
+		return nil
+	}
+	extracted, ok := extractJSON(content)
+	if !ok {
+		return fmt.Errorf("no JSON object found: %w", err)
+	}
+	return json.Unmarshal([]byte(extracted), v)
+}
+
+// extractJSON returns the first balanced JSON object in s with // and /* */
+// comments and trailing commas removed. Prose may contain braces of its own
+// ("Here is {an example}: {...}"), so a candidate that is not valid JSON is
+// skipped and the search goes on from the next '{'.
+func extractJSON(s string) (string, bool) {
+	for start := 0; ; start++ {
+		next := strings.IndexByte(s[start:], '{')
+		if next < 0 {
+			return "", false
+		}
+		start += next
+		if candidate, ok := balancedFrom(s, start); ok && json.Valid([]byte(candidate)) {
+			return candidate, true
+		}
+	}
+}
+
+// balancedFrom returns the balanced object or array that starts at
+// s[start], cleaned up as described at extractJSON.
+func balancedFrom(s string, start int) (string, bool) {
+	var out strings.Builder
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+
+		if inString {
+			out.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(s) && s[i+1] == '/':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return "", false
+			}
+			i += end + 3
+			continue
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			trimTrailingComma(&out)
+			depth--
+		}
+		out.WriteByte(c)
+
+		if depth == 0 {
+			return out.String(), true
+		}
+	}
+	return "", false
+}
+
+// trimTrailingComma drops a comma (and the whitespace after it) at the end of b.
+func trimTrailingComma(b *strings.Builder) {
+	s := strings.TrimRight(b.String(), " \t\r\n")
+	if strings.HasSuffix(s, ",") {
+		b.Reset()
+		b.WriteString(s[:len(s)-1])
+	}
+}
@@ -0,0 +1,55 @@
+package agents
+
+import "testing"
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // "" means no object is found
+	}{
+		{"plain", `{"a": 1}`, `{"a": 1}`},
+		{"fenced", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"leading prose", `Sure! Here is the flow: {"a": 1}`, `{"a": 1}`},
+		{"trailing text", `{"a": 1} Let me know if you need changes.`, `{"a": 1}`},
+		{"braces in prose", `Here is {an example}: {"a": 1}`, `{"a": 1}`},
+		{"braces in strings", `{"a": "}{", "b": "\"{"}`, `{"a": "}{", "b": "\"{"}`},
+		{"nested", `x {"a": {"b": [1, {"c": 2}]}} y`, `{"a": {"b": [1, {"c": 2}]}}`},
+		{"comments", "{\"a\": 1, // one\n\"b\": /* two */ 2}", `{"a": 1, "b":  2}`},
+		{"trailing commas", `{"a": [1, 2,], "b": 3,}`, `{"a": [1, 2], "b": 3}`},
+		{"no object", `I cannot help with that.`, ""},
+		{"unbalanced", `{"a": 1`, ""},
+		{"only prose braces", `a {b} c {d}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractJSON(tt.in)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("extractJSON(%q) = %q, %v; want %q", tt.in, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"strict", `{"n": 1}`, 1, false},
+		{"fenced", "```json\n{\"n\": 2}\n```", 2, false},
+		{"braces in prose", "Here is {an example}:\n{\"n\": 3}\nDone.", 3, false},
+		{"no object", "no JSON here", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct{ N int }
+			err := decodeJSON(tt.in, &v)
+			if (err != nil) != tt.wantErr || v.N != tt.want {
+				t.Errorf("decodeJSON(%q) = %+v, %v; want n=%d, error %v", tt.in, v, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// BranchTypes are the connection types. Every type but "out" is a branch
+// of a decision.
+var BranchTypes = []string{"out", "yes", "no", "branch", "default"}
+
+// Issue is a structural problem found by Validate.
+type Issue struct {
+	NodeID  string `json:"node_id,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.NodeID == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("node %q: %s", i.NodeID, i.Message)
+}
+
+// Validate checks the structure of a flowchart without asking an LLM:
+// dangling and duplicate IDs, node types that are not registered (see
+// flow.NodeTypes), decisions with missing or ambiguous branches, forks
+// and joins with a single path, nodes outside the flow's lanes,
+// subprocesses whose child flow is missing or has no start/end,
+// unreachable nodes, dead ends and missing start/end nodes. Annotations
+// are comments rather than steps and skip the connection checks.
+// Embedded child flows are validated as well; referenced flow files are
+// resolved against the working directory, where Nodey saves flows.
+func Validate(fc flow.Flowchart) []Issue {
+	var issues []Issue
+
+	nodes := make(map[string]flow.Node, len(fc.Nodes))
+	var unique []flow.Node // first occurrence of every id
+	for _, n := range fc.Nodes {
+		if n.ID == "" {
+			issues = append(issues, Issue{Message: fmt.Sprintf("node %q has no id", n.Title)})
+			continue
+		}
+		if _, dup := nodes[n.ID]; dup {
+			issues = append(issues, Issue{NodeID: n.ID, Message: "duplicate node id"})
+			continue
+		}
+		nodes[n.ID] = n
+		unique = append(unique, n)
+		if !isNodeType(n.Type) {
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("unknown type %q, expected one of %s", n.Type, strings.Join(flow.NodeTypeNames(), ", "))})
+		}
+		switch {
+		case n.Type == "subprocess":
+			issues = append(issues, checkSubflow(n)...)
+		case n.Subflow != nil:
+			issues = append(issues, Issue{NodeID: n.ID, Message: `only "subprocess" nodes may have a subflow`})
+		}
+	}
+
+	issues = append(issues, checkLanes(fc.Lanes, unique)...)
+
+	out := map[string][]flow.Connection{}
+	in := map[string]int{}
+	for _, c := range fc.Connections {
+		_, fromOK := nodes[c.From]
+		_, toOK := nodes[c.To]
+		if !fromOK {
+			issues = append(issues, Issue{Message: fmt.Sprintf("connection %s -> %s starts at unknown node %q", c.From, c.To, c.From)})
+		}
+		if !toOK {
+			issues = append(issues, Issue{Message: fmt.Sprintf("connection %s -> %s ends at unknown node %q", c.From, c.To, c.To)})
+		}
+		if fromOK && toOK {
+			out[c.From] = append(out[c.From], c)
+			in[c.To]++
+			if (c.Type == "branch" || c.Type == "default") && nodes[c.From].Type != "decision" {
+				issues = append(issues, Issue{NodeID: c.From, Message: fmt.Sprintf("connection to %q is a %q, but only decisions have branches", c.To, c.Type)})
+			}
+		}
+	}
+
+	var starts []string
+	hasEnd := false
+	for _, n := range unique {
+		switch n.Type {
+		case "start":
+			starts = append(starts, n.ID)
+		case "end":
+			hasEnd = true
+		}
+	}
+	if len(starts) == 0 {
+		issues = append(issues, Issue{Message: `flow has no "start" node`})
+	}
+	if !hasEnd {
+		issues = append(issues, Issue{Message: `flow has no "end" node`})
+	}
+
+	for _, n := range unique {
+		edges := out[n.ID]
+		switch {
+		case n.Type == "decision":
+			issues = append(issues, checkBranches(n, edges)...)
+		case n.Type == "annotation":
+		case n.Type == "fork" && len(edges) < 2:
+			issues = append(issues, Issue{NodeID: n.ID, Message: "fork needs at least two outgoing connections"})
+		case n.Type == "join" && in[n.ID] < 2:
+			issues = append(issues, Issue{NodeID: n.ID, Message: "join needs at least two incoming connections"})
+		case n.Type != "end" && len(edges) == 0:
+			issues = append(issues, Issue{NodeID: n.ID, Message: `dead end: only "end" nodes may have no outgoing connection`})
+		}
+	}
+
+	// Everything must be reachable from a start node.
+	if len(starts) > 0 {
+		seen := map[string]bool{}
+		queue := append([]string(nil), starts...)
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			for _, c := range out[id] {
+				queue = append(queue, c.To)
+			}
+		}
+		for _, n := range unique {
+			if !seen[n.ID] && n.Type != "annotation" {
+				issues = append(issues, Issue{NodeID: n.ID, Message: "unreachable from the start node"})
+			}
+		}
+	}
+
+	return issues
+}
+
+// checkBranches checks the outgoing connections of a decision: at least
+// two branches, each with a label that no other branch uses, at most one
+// default, and both sides of a yes/no pair.
+func checkBranches(n flow.Node, edges []flow.Connection) []Issue {
+	var issues []Issue
+	if len(edges) < 2 {
+		issues = append(issues, Issue{NodeID: n.ID, Message: "decision needs at least two branches"})
+	}
+	labels := map[string]bool{}
+	types := map[string]int{}
+	for _, c := range edges {
+		types[c.Type]++
+		switch c.Type {
+		case "yes", "no", "default":
+		case "branch":
+			if strings.TrimSpace(c.Label) == "" {
+				issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("branch to %q needs a label", c.To)})
+				continue
+			}
+		default:
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf(`connection to %q must be a "yes", "no", "branch" or "default" branch, not %q`, c.To, c.Type)})
+			continue
+		}
+		label := strings.ToLower(strings.TrimSpace(c.BranchLabel()))
+		if labels[label] {
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("branch label %q is used more than once", c.BranchLabel())})
+		}
+		labels[label] = true
+	}
+	if types["default"] > 1 {
+		issues = append(issues, Issue{NodeID: n.ID, Message: `decision has more than one "default" branch`})
+	}
+	if (types["yes"] > 0) != (types["no"] > 0) {
+		issues = append(issues, Issue{NodeID: n.ID, Message: `decision with a "yes" branch needs a "no" branch and vice versa`})
+	}
+	return issues
+}
+
+// checkSubflow checks that a subprocess has a child flow with a start and
+// an end. An embedded child is validated in full; a referenced file is
+// validated when it is edited itself.
+func checkSubflow(n flow.Node) []Issue {
+	if n.Subflow == nil {
+		return []Issue{{NodeID: n.ID, Message: "subprocess has no subflow"}}
+	}
+	child, err := n.Subflow.Child("")
+	if err != nil {
+		return []Issue{{NodeID: n.ID, Message: "subflow: " + err.Error()}}
+	}
+	var issues []Issue
+	if n.Subflow.Flow != nil {
+		for _, issue := range Validate(child) {
+			issues = append(issues, Issue{NodeID: n.ID, Message: "subflow: " + issue.String()})
+		}
+		return issues
+	}
+	has := map[string]bool{}
+	for _, c := range child.Nodes {
+		has[c.Type] = true
+	}
+	for _, t := range []string{"start", "end"} {
+		if !has[t] {
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("subflow %s has no %q node", n.Subflow.Ref, t)})
+		}
+	}
+	return issues
+}
+
+// checkLanes checks that the lanes are named and distinct and that every
+// node is in one of them. Flows without lanes must not assign any.
+func checkLanes(lanes []string, nodes []flow.Node) []Issue {
+	var issues []Issue
+	known := map[string]bool{}
+	for _, l := range lanes {
+		if strings.TrimSpace(l) == "" {
+			issues = append(issues, Issue{Message: "lane has no name"})
+			continue
+		}
+		if known[l] {
+			issues = append(issues, Issue{Message: fmt.Sprintf("lane %q is listed more than once", l)})
+		}
+		known[l] = true
+	}
+	for _, n := range nodes {
+		switch {
+		case len(lanes) == 0 && n.Lane != "":
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("lane %q is not declared: the flow has no lanes", n.Lane)})
+		case len(lanes) > 0 && n.Lane == "":
+			issues = append(issues, Issue{NodeID: n.ID, Message: "node has no lane"})
+		case n.Lane != "" && !known[n.Lane]:
+			issues = append(issues, Issue{NodeID: n.ID, Message: fmt.Sprintf("unknown lane %q, expected one of %s", n.Lane, strings.Join(lanes, ", "))})
+		}
+	}
+	return issues
+}
+
+func isBranchType(t string) bool {
+	for _, known := range BranchTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func isNodeType(t string) bool {
+	_, ok := flow.LookupNodeType(t)
+	return ok
+}
+
+// FormatIssues renders issues as a numbered list for critique and display.
+func FormatIssues(issues []Issue) string {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, issue)
+	}
+	return strings.Join(lines, "\n")
+}
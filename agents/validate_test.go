@@ -0,0 +1,201 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// checkIssues validates fc and compares the issues with want.
+func checkIssues(t *testing.T, fc flow.Flowchart, want []string) {
+	t.Helper()
+	wantIssues(t, Validate(fc), want)
+}
+
+// wantIssues compares issues with want, each of which must be a substring
+// of one issue, in order.
+func wantIssues(t *testing.T, issues []Issue, want []string) {
+	t.Helper()
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d:\n%s", len(issues), len(want), FormatIssues(issues))
+	}
+	for i, w := range want {
+		if !strings.Contains(issues[i].String(), w) {
+			t.Errorf("issue %d is %q, want it to contain %q", i+1, issues[i], w)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*flow.Flowchart)
+		want   []string
+	}{
+		{"clean", func(*flow.Flowchart) {}, nil},
+		{"dangling target", func(fc *flow.Flowchart) {
+			fc.Connections = append(fc.Connections, flow.Connection{From: "enter", To: "n9", Type: "out"})
+		}, []string{`connection enter -> n9 ends at unknown node "n9"`}},
+		{"dangling source", func(fc *flow.Flowchart) {
+			fc.Connections = append(fc.Connections, flow.Connection{From: "n9", To: "enter", Type: "out"})
+		}, []string{`connection n9 -> enter starts at unknown node "n9"`}},
+		{"duplicate id", func(fc *flow.Flowchart) {
+			fc.Nodes = append(fc.Nodes, flow.Node{ID: "enter", Type: "action", Lane: "User"})
+		}, []string{`node "enter": duplicate node id`}},
+		{"missing id", func(fc *flow.Flowchart) {
+			fc.Nodes = append(fc.Nodes, flow.Node{Type: "action", Title: "Orphan", Lane: "User"})
+		}, []string{`node "Orphan" has no id`}},
+		{"unknown type", func(fc *flow.Flowchart) {
+			fc.Nodes[1].Type = "teleport"
+		}, []string{`node "enter": unknown type "teleport"`}},
+		{"unreachable", func(fc *flow.Flowchart) {
+			fc.Nodes = append(fc.Nodes, flow.Node{ID: "island", Type: "action", Lane: "User"})
+			fc.Connections = append(fc.Connections, flow.Connection{From: "island", To: "ok", Type: "out"})
+		}, []string{`node "island": unreachable`}},
+		{"annotations may float", func(fc *flow.Flowchart) {
+			fc.Nodes = append(fc.Nodes, flow.Node{ID: "note", Type: "annotation", Lane: "User"})
+		}, nil},
+		{"dead end", func(fc *flow.Flowchart) {
+			fc.Connections = fc.Connections[:1] // enter leads nowhere
+		}, []string{
+			`node "enter": dead end`,
+			`node "check": decision needs at least two branches`,
+			`node "check": unreachable`,
+			`node "ok": unreachable`,
+			`node "denied": unreachable`,
+		}},
+		{"no start or end", func(fc *flow.Flowchart) {
+			fc.Nodes[0].Type = "action"
+			fc.Nodes[3].Type = "action"
+			fc.Nodes[4].Type = "action"
+		}, []string{`no "start" node`, `no "end" node`, `node "ok": dead end`, `node "denied": dead end`}},
+		{"branch outside a decision", func(fc *flow.Flowchart) {
+			fc.Connections[0].Type = "default"
+		}, []string{`node "start": connection to "enter" is a "default", but only decisions have branches`}},
+		{"fork and join", func(fc *flow.Flowchart) {
+			fc.Nodes[1].Type = "fork"
+			fc.Nodes = append(fc.Nodes, flow.Node{ID: "join", Type: "join", Lane: "Auth"})
+			fc.Connections = append(fc.Connections, flow.Connection{From: "join", To: "check", Type: "out"})
+		}, []string{
+			`node "enter": fork needs at least two outgoing connections`,
+			`node "join": join needs at least two incoming connections`,
+			`node "join": unreachable`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := login()
+			tt.change(&fc)
+			checkIssues(t, fc, tt.want)
+		})
+	}
+}
+
+func TestFormatIssues(t *testing.T) {
+	got := FormatIssues([]Issue{{Message: "flow has no start"}, {NodeID: "n2", Message: "dead end"}})
+	if want := "1. flow has no start\n2. node \"n2\": dead end"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCheckBranches(t *testing.T) {
+	d := flow.Node{ID: "d", Type: "decision"}
+	conn := func(to, typ, label string) flow.Connection {
+		return flow.Connection{From: "d", To: to, Type: typ, Label: label}
+	}
+	tests := []struct {
+		name  string
+		edges []flow.Connection
+		want  []string
+	}{
+		{"yes and no", []flow.Connection{conn("a", "yes", ""), conn("b", "no", "")}, nil},
+		{"labelled branches", []flow.Connection{conn("a", "branch", "Card"), conn("b", "branch", "PayPal"), conn("c", "default", "")}, nil},
+		{"one branch", []flow.Connection{conn("a", "yes", "")}, []string{
+			"at least two branches",
+			`"yes" branch needs a "no" branch`,
+		}},
+		{"yes without no", []flow.Connection{conn("a", "yes", ""), conn("b", "branch", "Later")}, []string{`"yes" branch needs a "no" branch`}},
+		{"no without yes", []flow.Connection{conn("a", "no", ""), conn("b", "default", "")}, []string{`"yes" branch needs a "no" branch`}},
+		{"duplicate labels", []flow.Connection{conn("a", "branch", "Card"), conn("b", "branch", " card ")}, []string{`branch label " card " is used more than once`}},
+		{"label clashes with a yes", []flow.Connection{conn("a", "yes", ""), conn("b", "no", ""), conn("c", "branch", "Yes")}, []string{`branch label "Yes" is used more than once`}},
+		{"unlabelled branch", []flow.Connection{conn("a", "branch", " "), conn("b", "default", "")}, []string{`branch to "a" needs a label`}},
+		{"two defaults", []flow.Connection{conn("a", "branch", "Card"), conn("b", "default", "Other"), conn("c", "default", "Else")}, []string{`more than one "default" branch`}},
+		{"out from a decision", []flow.Connection{conn("a", "out", ""), conn("b", "default", "")}, []string{`connection to "a" must be a "yes", "no", "branch" or "default" branch, not "out"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantIssues(t, checkBranches(d, tt.edges), tt.want)
+		})
+	}
+}
+
+func TestCheckLanes(t *testing.T) {
+	node := func(id, lane string) flow.Node { return flow.Node{ID: id, Type: "action", Lane: lane} }
+	tests := []struct {
+		name  string
+		lanes []string
+		nodes []flow.Node
+		want  []string
+	}{
+		{"no lanes", nil, []flow.Node{node("a", ""), node("b", "")}, nil},
+		{"every node in a lane", []string{"User", "Auth"}, []flow.Node{node("a", "User"), node("b", "Auth")}, nil},
+		{"node without a lane", []string{"User"}, []flow.Node{node("a", "User"), node("b", "")}, []string{`node "b": node has no lane`}},
+		{"unknown lane", []string{"User", "Auth"}, []flow.Node{node("a", "Bank")}, []string{`node "a": unknown lane "Bank", expected one of User, Auth`}},
+		{"lane in a flow without lanes", nil, []flow.Node{node("a", "User")}, []string{`node "a": lane "User" is not declared`}},
+		{"unnamed lane", []string{"User", " "}, []flow.Node{node("a", "User")}, []string{"lane has no name"}},
+		{"repeated lane", []string{"User", "User"}, []flow.Node{node("a", "User")}, []string{`lane "User" is listed more than once`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantIssues(t, checkLanes(tt.lanes, tt.nodes), tt.want)
+		})
+	}
+
+	// Validate reports lane issues for the whole flow.
+	fc := login()
+	fc.Nodes[2].Lane = "Bank"
+	checkIssues(t, fc, []string{`node "check": unknown lane "Bank"`})
+}
+
+func TestCheckSubflow(t *testing.T) {
+	t.Chdir(t.TempDir()) // referenced flows resolve against the working directory
+	if err := flow.Save("payment_flow.json", login()); err != nil {
+		t.Fatal(err)
+	}
+	headless := login()
+	headless.Nodes[0].Type = "action"
+	if err := flow.Save("headless_flow.json", headless); err != nil {
+		t.Fatal(err)
+	}
+	broken := login()
+	broken.Connections = append(broken.Connections, flow.Connection{From: "enter", To: "n9", Type: "out"})
+
+	sub := func(s *flow.Subflow) flow.Node {
+		return flow.Node{ID: "sub", Type: "subprocess", Title: "Pay", Subflow: s}
+	}
+	tests := []struct {
+		name string
+		node flow.Node
+		want []string
+	}{
+		{"referenced", sub(&flow.Subflow{Ref: "payment_flow.json"}), nil},
+		{"embedded", sub(&flow.Subflow{Flow: &flow.Flowchart{Nodes: login().Nodes, Lanes: login().Lanes, Connections: login().Connections}}), nil},
+		{"no subflow", sub(nil), []string{"subprocess has no subflow"}},
+		{"empty subflow", sub(&flow.Subflow{}), []string{"neither a ref nor an embedded flow"}},
+		{"both", sub(&flow.Subflow{Ref: "payment_flow.json", Flow: &flow.Flowchart{}}), []string{"both a ref and an embedded flow"}},
+		{"missing file", sub(&flow.Subflow{Ref: "gone_flow.json"}), []string{`node "sub": subflow: open gone_flow.json`}},
+		{"no start", sub(&flow.Subflow{Ref: "headless_flow.json"}), []string{`subflow headless_flow.json has no "start" node`}},
+		{"invalid embedded flow", sub(&flow.Subflow{Flow: &broken}), []string{`node "sub": subflow: connection enter -> n9 ends at unknown node "n9"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantIssues(t, checkSubflow(tt.node), tt.want)
+		})
+	}
+
+	// Only subprocesses may carry a subflow.
+	fc := login()
+	fc.Nodes[1].Subflow = &flow.Subflow{Ref: "payment_flow.json"}
+	checkIssues(t, fc, []string{`node "enter": only "subprocess" nodes may have a subflow`})
+}
@@ -0,0 +1,649 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DN-OpenSource/nodey/agents"
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+// FileName is the project-local configuration file.
+const FileName = "nodey.json"
+
+// ProjectPromptsDir holds project-local prompt overrides.
+const ProjectPromptsDir = ".nodey/prompts"
+
+// Review policies.
+const (
+	FailOpen   = "fail-open"   // ship unapproved drafts, marked as such
+	FailClosed = "fail-closed" // never ship unapproved drafts; retry or edit
+	AskUser    = "ask-user"    // let the user accept, retry or edit
+)
+
+// Architect modes.
+const (
+	ArchitectJSON  = "json"  // one JSON reply per draft
+	ArchitectTools = "tools" // build the graph through tool calls
+)
+
+// Config is the effective configuration of a Nodey session.
+//
+// It is assembled in layers, each overriding the previous one:
+// built-in defaults, the config file, NODEY_* environment variables and
+// finally command line flags.
+type Config struct {
+	// BaseURL points Nodey at an OpenAI-compatible server instead of api.openai.com.
+	BaseURL string `json:"base_url,omitempty"`
+
+	// StructuredOutputs forces schema-constrained output on or off. When unset
+	// it is on for api.openai.com and off for OpenAI-compatible servers.
+	StructuredOutputs *bool `json:"structured_outputs,omitempty"`
+
+	// CassetteMode is "record", "replay" or empty. Cassettes are stored in CassetteDir.
+	CassetteMode string `json:"cassette_mode,omitempty"`
+	CassetteDir  string `json:"cassette_dir,omitempty"`
+
+	// DocsDir is a directory of team documents (Markdown, text, OpenAPI
+	// YAML, Go) the Researcher searches and cites. Empty disables retrieval.
+	DocsDir string `json:"docs_dir,omitempty"`
+
+	// ResearchCacheDir stores research reports for reuse across sessions.
+	// "off" disables the cache.
+	ResearchCacheDir string `json:"research_cache_dir,omitempty"`
+
+	// PromptsDir is a prompt pack: a directory of *.tmpl files that override
+	// the built-in system prompts. It is applied after UserPromptsDir and
+	// ProjectPromptsDir, which are picked up when they exist.
+	PromptsDir string `json:"prompts_dir,omitempty"`
+
+	// Redact masks secrets and personal data in every prompt before it is
+	// sent, and restores them in the saved flow.
+	Redact Redact `json:"redact"`
+
+	// MaxRepairs bounds how often an agent is asked to fix invalid JSON output.
+	MaxRepairs int `json:"max_repairs"`
+
+	// Timeout bounds every single agent call, e.g. "2m". Zero means no limit.
+	Timeout Duration `json:"timeout"`
+
+	// Retry controls how rate limits, server errors and dropped connections
+	// are retried. It applies to every agent call.
+	Retry Retry `json:"retry"`
+
+	Analyst    agents.Settings `json:"analyst"`
+	Researcher agents.Settings `json:"researcher"`
+	Architect  agents.Settings `json:"architect"`
+	Judge      agents.Settings `json:"judge"`
+
+	// ArchitectMode is ArchitectJSON or ArchitectTools. Tools mode needs a
+	// model and server with function calling support.
+	ArchitectMode string `json:"architect_mode"`
+
+	// MaxRevisions is how often a rejected draft goes back to the Architect.
+	MaxRevisions int `json:"max_revisions"`
+
+	// ReviewPolicy decides what happens to a draft the judges did not approve
+	// (because they failed or max_revisions ran out): FailOpen ships it,
+	// FailClosed and AskUser stop and let the user decide.
+	ReviewPolicy string `json:"review_policy"`
+
+	// Judges is the review panel; empty means agents.DefaultPanel.
+	// JudgePolicy decides how their votes combine: unanimous, majority or weighted.
+	Judges      []agents.Juror `json:"judges,omitempty"`
+	JudgePolicy string         `json:"judge_policy"`
+
+	// Budget stops a run once it has used more tokens or dollars than
+	// allowed. Prices (USD per million tokens) extend agents.DefaultPrices.
+	Budget agents.Budget           `json:"budget"`
+	Prices map[string]agents.Price `json:"prices,omitempty"`
+
+	// NodeTypes are custom node types (name, shape, color and optionally
+	// size and description) added to the built-in ones in flow.NodeTypes.
+	// They can only be set in the config file.
+	NodeTypes []flow.NodeType `json:"node_types,omitempty"`
+
+	// Path is the config file that was loaded, if any.
+	Path string `json:"-"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	s := agents.Settings{Model: agents.DefaultModel}
+	cache := "off"
+	if dir, err := os.UserCacheDir(); err == nil {
+		cache = filepath.Join(dir, "nodey", "research")
+	}
+	return Config{
+		CassetteDir:      "cassettes",
+		ResearchCacheDir: cache,
+		MaxRepairs:       agents.DefaultMaxRepairs,
+		Timeout:          Duration{3 * time.Minute},
+		Retry: Retry{
+			MaxAttempts: agents.DefaultRetryPolicy.MaxAttempts,
+			BaseDelay:   Duration{agents.DefaultRetryPolicy.BaseDelay},
+			MaxDelay:    Duration{agents.DefaultRetryPolicy.MaxDelay},
+		},
+		Redact:        Redact{Detectors: agents.DetectorNames},
+		JudgePolicy:   agents.PolicyUnanimous,
+		MaxRevisions:  3,
+		ArchitectMode: ArchitectJSON,
+		ReviewPolicy:  FailOpen,
+		Analyst:       s,
+		Researcher:    s,
+		Architect:     s,
+		Judge:         s,
+	}
+}
+
+// Agent returns the settings of the named agent, or nil if there is no such agent.
+func (c *Config) Agent(name string) *agents.Settings {
+	switch name {
+	case agents.Analyst:
+		return &c.Analyst
+	case agents.Researcher:
+		return &c.Researcher
+	case agents.Architect:
+		return &c.Architect
+	case agents.Judge:
+		return &c.Judge
+	}
+	return nil
+}
+
+// AgentSettings returns a copy of every agent's settings keyed by agent name.
+func (c Config) AgentSettings() map[string]agents.Settings {
+	out := make(map[string]agents.Settings, len(agents.Names))
+	for _, name := range agents.Names {
+		out[name] = *c.Agent(name)
+	}
+	return out
+}
+
+// Load builds the effective configuration from the config file, the
+// environment and the given command line arguments (without the program name).
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("nodey", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a JSON config file (default ./"+FileName+" or the user config dir)")
+	var overrides []func(*Config) error
+	fs.Func("base-url", "OpenAI-compatible base URL", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.BaseURL = v; return nil })
+		return nil
+	})
+	fs.Func("structured-outputs", "request JSON-schema constrained output (true/false)", func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.StructuredOutputs = &b; return nil })
+		return nil
+	})
+	fs.Func("record", "record every LLM call as a cassette in `dir`", func(v string) error {
+		overrides = append(overrides, func(c *Config) error {
+			c.CassetteMode, c.CassetteDir = agents.CassetteRecord, v
+			return nil
+		})
+		return nil
+	})
+	fs.Func("replay", "serve LLM calls from the cassettes in `dir`, without network", func(v string) error {
+		overrides = append(overrides, func(c *Config) error {
+			c.CassetteMode, c.CassetteDir = agents.CassetteReplay, v
+			return nil
+		})
+		return nil
+	})
+	fs.Func("docs", "let the Researcher search and cite the documents in `dir`", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.DocsDir = v; return nil })
+		return nil
+	})
+	fs.Func("research-cache", "directory for cached research reports, or \"off\"", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.ResearchCacheDir = v; return nil })
+		return nil
+	})
+	fs.Func("prompts", "override the built-in prompts with the *.tmpl files in `dir`", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.PromptsDir = v; return nil })
+		return nil
+	})
+	fs.Func("redact", "comma-separated detectors to mask in prompts ("+strings.Join(agents.DetectorNames, ",")+"), or \"off\"", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.Redact.Detectors = parseDetectors(v); return nil })
+		return nil
+	})
+	fs.Func("budget-tokens", "stop a run after this many tokens", func(v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.Budget.Tokens = n; return nil })
+		return nil
+	})
+	fs.Func("budget-usd", "stop a run after spending this many dollars", func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.Budget.USD = f; return nil })
+		return nil
+	})
+	fs.Func("max-repairs", "how often an agent may repair invalid output", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.MaxRepairs = n; return nil })
+		return nil
+	})
+	fs.Func("timeout", "timeout for a single agent call, e.g. 90s (0 disables)", func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.Timeout = Duration{d}; return nil })
+		return nil
+	})
+	fs.Func("max-attempts", "attempts per agent call before a transient failure is reported (1 disables retries)", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.Retry.MaxAttempts = n; return nil })
+		return nil
+	})
+	fs.Func("max-revisions", "how often a rejected draft goes back to the Architect", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, func(c *Config) error { c.MaxRevisions = n; return nil })
+		return nil
+	})
+	fs.Func("architect-mode", "how the Architect drafts: json or tools", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.ArchitectMode = v; return nil })
+		return nil
+	})
+	fs.Func("review-policy", "unapproved drafts: fail-open, fail-closed or ask-user", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.ReviewPolicy = v; return nil })
+		return nil
+	})
+	fs.Func("judge-policy", "how judge votes combine: unanimous, majority or weighted", func(v string) error {
+		overrides = append(overrides, func(c *Config) error { c.JudgePolicy = v; return nil })
+		return nil
+	})
+	fs.Func("model", "model for every agent", func(v string) error {
+		overrides = append(overrides, func(c *Config) error {
+			for _, name := range agents.Names {
+				c.Agent(name).Model = v
+			}
+			return nil
+		})
+		return nil
+	})
+	for _, name := range agents.Names {
+		for _, key := range settingKeys {
+			name, key := name, key
+			flagName := name + "-" + strings.ReplaceAll(key, "_", "-")
+			fs.Func(flagName, key+" for the "+name, func(v string) error {
+				overrides = append(overrides, func(c *Config) error {
+					return setSetting(c.Agent(name), key, v)
+				})
+				return nil
+			})
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.loadFile(*path); err != nil {
+		return cfg, err
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, err
+	}
+	for _, apply := range overrides {
+		if err := apply(&cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// PromptDirs lists the directories whose templates override the built-in
+// prompts, in the order they apply: the user's prompts, the project's and
+// finally PromptsDir. The first two are optional and skipped if missing.
+func (c Config) PromptDirs() []string {
+	var candidates []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "nodey", "prompts"))
+	}
+	candidates = append(candidates, ProjectPromptsDir)
+
+	var dirs []string
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	if c.PromptsDir != "" {
+		dirs = append(dirs, c.PromptsDir)
+	}
+	return dirs
+}
+
+// loadFile overlays the config file on c. An explicit path must exist;
+// the default locations are optional.
+func (c *Config) loadFile(path string) error {
+	candidates := []string{path}
+	if path == "" {
+		candidates = []string{FileName}
+		if dir, err := os.UserConfigDir(); err == nil {
+			candidates = append(candidates, filepath.Join(dir, "nodey", "config.json"))
+		}
+	}
+
+	for _, p := range candidates {
+		data, err := os.ReadFile(p)
+		if errors.Is(err, os.ErrNotExist) && path == "" {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read config: %w", err)
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return fmt.Errorf("failed to parse config %s: %w", p, err)
+		}
+		c.Path = p
+		return nil
+	}
+	return nil
+}
+
+// loadEnv overlays NODEY_* environment variables on c, e.g. NODEY_MODEL
+// or NODEY_ARCHITECT_MAX_TOKENS. OPENAI_BASE_URL is honoured as well.
+func (c *Config) loadEnv() error {
+	if v := os.Getenv("OPENAI_BASE_URL"); v != "" {
+		c.BaseURL = v
+	}
+	if v := os.Getenv("NODEY_BASE_URL"); v != "" {
+		c.BaseURL = v
+	}
+	if v := os.Getenv("NODEY_STRUCTURED_OUTPUTS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("NODEY_STRUCTURED_OUTPUTS: %w", err)
+		}
+		c.StructuredOutputs = &b
+	}
+	if v := os.Getenv("NODEY_CASSETTE_MODE"); v != "" {
+		c.CassetteMode = v
+	}
+	if v := os.Getenv("NODEY_CASSETTE_DIR"); v != "" {
+		c.CassetteDir = v
+	}
+	if v := os.Getenv("NODEY_DOCS_DIR"); v != "" {
+		c.DocsDir = v
+	}
+	if v := os.Getenv("NODEY_RESEARCH_CACHE_DIR"); v != "" {
+		c.ResearchCacheDir = v
+	}
+	if v := os.Getenv("NODEY_PROMPTS_DIR"); v != "" {
+		c.PromptsDir = v
+	}
+	if v := os.Getenv("NODEY_REDACT"); v != "" {
+		c.Redact.Detectors = parseDetectors(v)
+	}
+	if v := os.Getenv("NODEY_BUDGET_TOKENS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("NODEY_BUDGET_TOKENS: %w", err)
+		}
+		c.Budget.Tokens = n
+	}
+	if v := os.Getenv("NODEY_BUDGET_USD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("NODEY_BUDGET_USD: %w", err)
+		}
+		c.Budget.USD = f
+	}
+	if v := os.Getenv("NODEY_MAX_REPAIRS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("NODEY_MAX_REPAIRS: %w", err)
+		}
+		c.MaxRepairs = n
+	}
+	if v := os.Getenv("NODEY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("NODEY_TIMEOUT: %w", err)
+		}
+		c.Timeout = Duration{d}
+	}
+	if v := os.Getenv("NODEY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("NODEY_MAX_ATTEMPTS: %w", err)
+		}
+		c.Retry.MaxAttempts = n
+	}
+	if v := os.Getenv("NODEY_MAX_REVISIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("NODEY_MAX_REVISIONS: %w", err)
+		}
+		c.MaxRevisions = n
+	}
+	if v := os.Getenv("NODEY_ARCHITECT_MODE"); v != "" {
+		c.ArchitectMode = v
+	}
+	if v := os.Getenv("NODEY_REVIEW_POLICY"); v != "" {
+		c.ReviewPolicy = v
+	}
+	if v := os.Getenv("NODEY_JUDGE_POLICY"); v != "" {
+		c.JudgePolicy = v
+	}
+	if v := os.Getenv("NODEY_MODEL"); v != "" {
+		for _, name := range agents.Names {
+			c.Agent(name).Model = v
+		}
+	}
+	for _, name := range agents.Names {
+		for _, key := range settingKeys {
+			env := "NODEY_" + strings.ToUpper(name+"_"+key)
+			if v, ok := os.LookupEnv(env); ok {
+				if err := setSetting(c.Agent(name), key, v); err != nil {
+					return fmt.Errorf("%s: %w", env, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// settingKeys are the per-agent settings that can be set from env and flags.
+var settingKeys = []string{"model", "temperature", "max_tokens", "reasoning_effort"}
+
+func setSetting(s *agents.Settings, key, value string) error {
+	switch key {
+	case "model":
+		s.Model = value
+	case "temperature":
+		if value == "" {
+			s.Temperature = nil
+			return nil
+		}
+		t, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid temperature %q", value)
+		}
+		s.Temperature = &t
+	case "max_tokens":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid max_tokens %q", value)
+		}
+		s.MaxTokens = n
+	case "reasoning_effort":
+		s.ReasoningEffort = value
+	}
+	return nil
+}
+
+// Validate reports the first setting that no provider would accept.
+func (c Config) Validate() error {
+	switch c.CassetteMode {
+	case "", agents.CassetteRecord, agents.CassetteReplay:
+	default:
+		return fmt.Errorf("unknown cassette_mode %q", c.CassetteMode)
+	}
+	if c.CassetteMode != "" && c.CassetteDir == "" {
+		return fmt.Errorf("cassette_dir must not be empty")
+	}
+	if c.MaxRepairs < 0 {
+		return fmt.Errorf("max_repairs must not be negative")
+	}
+	if c.Timeout.Duration < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if c.Budget.Tokens < 0 || c.Budget.USD < 0 {
+		return fmt.Errorf("budget must not be negative")
+	}
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1")
+	}
+	if c.Retry.BaseDelay.Duration < 0 || c.Retry.MaxDelay.Duration < c.Retry.BaseDelay.Duration {
+		return fmt.Errorf("retry delays must satisfy 0 <= base_delay <= max_delay")
+	}
+	if _, err := c.Redact.Redactor(); err != nil {
+		return fmt.Errorf("redact: %w", err)
+	}
+	if c.MaxRevisions < 0 {
+		return fmt.Errorf("max_revisions must not be negative")
+	}
+	seen := map[string]bool{}
+	for _, t := range c.NodeTypes {
+		if err := t.Check(); err != nil {
+			return fmt.Errorf("node_types: %w", err)
+		}
+		if _, exists := flow.LookupNodeType(t.Name); exists || seen[t.Name] {
+			return fmt.Errorf("node_types: node type %q already exists", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	switch c.ArchitectMode {
+	case ArchitectJSON, ArchitectTools:
+	default:
+		return fmt.Errorf("unknown architect_mode %q", c.ArchitectMode)
+	}
+	switch c.ReviewPolicy {
+	case FailOpen, FailClosed, AskUser:
+	default:
+		return fmt.Errorf("unknown review_policy %q", c.ReviewPolicy)
+	}
+	switch c.JudgePolicy {
+	case agents.PolicyUnanimous, agents.PolicyMajority, agents.PolicyWeighted:
+	default:
+		return fmt.Errorf("unknown judge_policy %q", c.JudgePolicy)
+	}
+	for _, j := range c.Judges {
+		if j.Name == "" {
+			return fmt.Errorf("every judge needs a name")
+		}
+		if j.Weight < 0 {
+			return fmt.Errorf("judge %s: weight must not be negative", j.Name)
+		}
+	}
+	for _, name := range agents.Names {
+		s := c.Agent(name)
+		if s.Model == "" {
+			return fmt.Errorf("%s: model must not be empty", name)
+		}
+		if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+			return fmt.Errorf("%s: temperature must be between 0 and 2", name)
+		}
+		if s.MaxTokens < 0 {
+			return fmt.Errorf("%s: max_tokens must not be negative", name)
+		}
+		switch s.ReasoningEffort {
+		case "", "minimal", "low", "medium", "high":
+		default:
+			return fmt.Errorf("%s: unknown reasoning_effort %q", name, s.ReasoningEffort)
+		}
+	}
+	return nil
+}
+
+// Retry is the configurable part of agents.RetryPolicy.
+type Retry struct {
+	MaxAttempts int      `json:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay"`
+	MaxDelay    Duration `json:"max_delay"`
+}
+
+// Policy converts r to the policy agents use.
+func (r Retry) Policy() agents.RetryPolicy {
+	return agents.RetryPolicy{MaxAttempts: r.MaxAttempts, BaseDelay: r.BaseDelay.Duration, MaxDelay: r.MaxDelay.Duration}
+}
+
+// Redact selects the built-in detectors to run and adds custom patterns
+// (name -> regular expression).
+type Redact struct {
+	Detectors []string          `json:"detectors"`
+	Patterns  map[string]string `json:"patterns,omitempty"`
+}
+
+// Redactor builds the redactor described by r, or nil if nothing is redacted.
+func (r Redact) Redactor() (*agents.Redactor, error) {
+	if len(r.Detectors) == 0 && len(r.Patterns) == 0 {
+		return nil, nil
+	}
+	return agents.NewRedactor(r.Detectors, r.Patterns)
+}
+
+// parseDetectors reads a -redact or NODEY_REDACT value: a comma-separated
+// list of detector names, or "off" for none.
+func parseDetectors(v string) []string {
+	if v == "off" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Duration is a time.Duration that reads and writes as "90s" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
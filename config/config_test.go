@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DN-OpenSource/nodey/agents"
+)
+
+// isolate runs the test in an empty directory with no user config and no
+// NODEY_* variables, so only what the test sets up is loaded.
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("OPENAI_BASE_URL", "")
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "NODEY_") {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	isolate(t)
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Path != "" || cfg.Judge.Model != agents.DefaultModel || cfg.ReviewPolicy != FailOpen || cfg.MaxRevisions != 3 {
+		t.Errorf("defaults are %+v", cfg)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	isolate(t)
+	writeConfig(t, FileName, `{
+		"base_url": "http://file",
+		"max_revisions": 5,
+		"review_policy": "ask-user",
+		"timeout": "90s",
+		"judge": {"model": "file-judge", "temperature": 0.5},
+		"architect": {"model": "file-architect"},
+		"researcher": {"model": "file-researcher"}
+	}`)
+	t.Setenv("NODEY_MAX_REVISIONS", "6")
+	t.Setenv("NODEY_BASE_URL", "http://env")
+	t.Setenv("NODEY_JUDGE_MODEL", "env-judge")
+	t.Setenv("NODEY_ARCHITECT_MODEL", "env-architect")
+
+	cfg, err := Load([]string{"-judge-model", "flag-judge", "-max-revisions", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		setting   string
+		got, want any
+	}{
+		{"path", cfg.Path, FileName},
+		{"review_policy (file)", cfg.ReviewPolicy, AskUser},
+		{"timeout (file)", cfg.Timeout.Duration, 90 * time.Second},
+		{"researcher model (file)", cfg.Researcher.Model, "file-researcher"},
+		{"judge temperature (file)", *cfg.Judge.Temperature, 0.5},
+		{"base_url (env over file)", cfg.BaseURL, "http://env"},
+		{"architect model (env over file)", cfg.Architect.Model, "env-architect"},
+		{"judge model (flag over env and file)", cfg.Judge.Model, "flag-judge"},
+		{"max_revisions (flag over env and file)", cfg.MaxRevisions, 7},
+		{"analyst model (default)", cfg.Analyst.Model, agents.DefaultModel},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s is %v, want %v", tt.setting, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadModelForEveryAgent(t *testing.T) {
+	isolate(t)
+	t.Setenv("NODEY_MODEL", "env-all")
+	t.Setenv("NODEY_JUDGE_MODEL", "env-judge") // the per-agent setting wins
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analyst.Model != "env-all" || cfg.Judge.Model != "env-judge" {
+		t.Errorf("analyst %q, judge %q", cfg.Analyst.Model, cfg.Judge.Model)
+	}
+
+	// Flags apply in the order given.
+	cfg, err = Load([]string{"-judge-model", "x", "-model", "flag-all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Judge.Model != "flag-all" {
+		t.Errorf("judge model is %q, want the later -model", cfg.Judge.Model)
+	}
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	isolate(t)
+	user := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "nodey", "config.json")
+	writeConfig(t, user, `{"max_revisions": 1}`)
+	cfg, err := Load(nil)
+	if err != nil || cfg.Path != user || cfg.MaxRevisions != 1 {
+		t.Errorf("user config: path %q, max_revisions %d, %v", cfg.Path, cfg.MaxRevisions, err)
+	}
+
+	// The project file is preferred over the user's.
+	writeConfig(t, FileName, `{"max_revisions": 2}`)
+	if cfg, err := Load(nil); err != nil || cfg.Path != FileName || cfg.MaxRevisions != 2 {
+		t.Errorf("project config: path %q, max_revisions %d, %v", cfg.Path, cfg.MaxRevisions, err)
+	}
+
+	if _, err := Load([]string{"-config", "missing.json"}); err == nil {
+		t.Error("an explicit config file that does not exist should be an error")
+	}
+	writeConfig(t, "bad.json", `{"timeout": 90}`)
+	if _, err := Load([]string{"-config", "bad.json"}); err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("an invalid config file gave %v", err)
+	}
+	t.Setenv("NODEY_MAX_REVISIONS", "many")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "NODEY_MAX_REVISIONS") {
+		t.Errorf("an invalid environment variable gave %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"empty judge model", func(c *Config) { c.Judge.Model = "" }, "judge: model must not be empty"},
+		{"unknown review policy", func(c *Config) { c.ReviewPolicy = "fail-soft" }, `unknown review_policy "fail-soft"`},
+		{"negative max_revisions", func(c *Config) { c.MaxRevisions = -1 }, "max_revisions must not be negative"},
+		{"unknown judge policy", func(c *Config) { c.JudgePolicy = "plurality" }, `unknown judge_policy "plurality"`},
+		{"unknown architect mode", func(c *Config) { c.ArchitectMode = "yaml" }, `unknown architect_mode "yaml"`},
+		{"temperature out of range", func(c *Config) { t := 3.0; c.Analyst.Temperature = &t }, "analyst: temperature must be between 0 and 2"},
+		{"unknown reasoning effort", func(c *Config) { c.Architect.ReasoningEffort = "max" }, `architect: unknown reasoning_effort "max"`},
+		{"unnamed judge", func(c *Config) { c.Judges = []agents.Juror{{Persona: "ux"}} }, "every judge needs a name"},
+		{"no attempts", func(c *Config) { c.Retry.MaxAttempts = 0 }, "retry.max_attempts must be at least 1"},
+		{"delays out of order", func(c *Config) { c.Retry.MaxDelay = Duration{time.Millisecond} }, "base_delay <= max_delay"},
+		{"unknown detector", func(c *Config) { c.Redact.Detectors = []string{"ssn"} }, "redact: "},
+		{"unknown cassette mode", func(c *Config) { c.CassetteMode = "rewind" }, `unknown cassette_mode "rewind"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("the defaults are invalid: %v", err)
+			}
+			tt.change(&cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
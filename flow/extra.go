@@ -0,0 +1,97 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// knownFields caches the JSON names of the fields of a struct type.
+var knownFields sync.Map // reflect.Type -> []string
+
+func fieldsOf(t reflect.Type) []string {
+	if names, ok := knownFields.Load(t); ok {
+		return names.([]string)
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	knownFields.Store(t, names)
+	return names
+}
+
+func isKnown(names []string, key string) bool {
+	for _, name := range names {
+		// encoding/json matches keys case-insensitively, so must we.
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// unmarshalKeepingExtra decodes data into v, a pointer to a struct, and
+// stores every key v has no field for in extra.
+func unmarshalKeepingExtra(data []byte, v any, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	names := fieldsOf(reflect.TypeOf(v).Elem())
+	for key := range all {
+		if isKnown(names, key) {
+			delete(all, key)
+		}
+	}
+	*extra = nil
+	if len(all) > 0 {
+		*extra = all
+	}
+	return nil
+}
+
+// marshalWithExtra encodes v, a struct, and appends the extra fields in
+// key order after its own.
+func marshalWithExtra(v any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	names := fieldsOf(reflect.TypeOf(v))
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if !isKnown(names, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1]) // drop the closing brace
+	for _, key := range keys {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LegacyFileName is the single file older versions of Nodey wrote every
+// flow to, before flows were saved as <title>_<timestamp>_flow.json.
+const LegacyFileName = "flowchart.json"
+
+// IsFlowFile reports whether a file name looks like a saved flow.
+func IsFlowFile(name string) bool {
+	return strings.HasSuffix(name, "_flow.json") || strings.EqualFold(name, LegacyFileName)
+}
+
+// Load reads a flow file, migrating it to SchemaVersion if it is older.
+// It also returns the version the file was written with.
+func Load(path string) (Flowchart, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Flowchart{}, 0, err
+	}
+	fc, from, err := Parse(data)
+	if err != nil {
+		return Flowchart{}, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return fc, from, nil
+}
+
+// Parse decodes a flow document of any supported version.
+func Parse(data []byte) (Flowchart, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep unknown numeric fields exactly as written
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return Flowchart{}, 0, err
+	}
+	from, err := Migrate(doc)
+	if err != nil {
+		return Flowchart{}, from, err
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return Flowchart{}, from, err
+	}
+	var fc Flowchart
+	if err := json.Unmarshal(migrated, &fc); err != nil {
+		return Flowchart{}, from, err
+	}
+	return fc, from, nil
+}
+
+// Marshal encodes fc in the current file format.
+func Marshal(fc Flowchart) ([]byte, error) {
+	fc.SchemaVersion = SchemaVersion
+	return json.MarshalIndent(fc, "", "  ")
+}
+
+// Save writes fc to path in the current file format.
+func Save(path string, fc Flowchart) error {
+	data, err := Marshal(fc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,146 @@
+// Package flow is Nodey's flowchart data model: the types every agent,
+// the generator and the TUI share, and the versioned file format they are
+// saved in.
+package flow
+
+import "encoding/json"
+
+// SchemaVersion is the version of the file format written by Save.
+// Files with an older version are migrated when they are loaded.
+const SchemaVersion = 1
+
+// Flowchart is a complete flow.
+type Flowchart struct {
+	// SchemaVersion is set by Save; Nodey never relies on the Architect for it.
+	SchemaVersion int `json:"schema_version,omitempty" schema:"-"`
+
+	Overview Overview `json:"overview"`
+
+	// Lanes are the actors of the flow (e.g. "User", "Auth service") in
+	// the order their swimlanes are drawn. Every node is in one of them.
+	// A flow without lanes is drawn without swimlanes.
+	Lanes []string `json:"lanes,omitempty"`
+
+	Nodes       []Node       `json:"nodes"`
+	Connections []Connection `json:"connections"`
+
+	// Meta records how the flow was produced. Its structure belongs to the
+	// agents package (agents.Metadata); the model keeps it as raw JSON so
+	// it round-trips untouched.
+	Meta json.RawMessage `json:"meta,omitempty" schema:"-"`
+
+	// Extra holds fields this version does not know, so they survive a
+	// load and save.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Overview is the title and summary of a flow.
+type Overview struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Node is one step of a flow.
+type Node struct {
+	ID    string `json:"id"`
+	Type  string `json:"type" enum:"@node_types"` // see NodeTypes
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Title string `json:"title"`
+	Notes string `json:"notes"` // Technical logic details
+
+	// Lane is the actor that performs the step, one of Flowchart.Lanes.
+	Lane string `json:"lane,omitempty"`
+
+	// Sources are the document citations (e.g. "docs/auth.md:12") the
+	// step is based on, carried over from the research report.
+	Sources []string `json:"sources,omitempty"`
+
+	// Subflow is the child flow of a "subprocess" node. It is never part
+	// of the Architect's schema: subflows are made by extracting a region
+	// of an existing flow, or by pointing Ref at a flow file by hand.
+	Subflow *Subflow `json:"subflow,omitempty" schema:"-"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Connection is a directed edge between two nodes. Type is the branch it
+// represents: "out" for an ordinary step and, when it leaves a decision,
+// "yes", "no", a labelled "branch" or the "default" taken when no other
+// branch applies. Any number of branches may leave one decision.
+type Connection struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type" enum:"out,yes,no,branch,default"`
+
+	// Label is shown on the edge. A "branch" is known by it (e.g. "PayPal",
+	// "5xx"); on any other connection it is an optional caption.
+	Label string `json:"label,omitempty"`
+
+	// Condition optionally says when the branch is taken, e.g. "status >= 500".
+	Condition string `json:"condition,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// BranchLabel is the name a decision branch is known by: its Label if it
+// has one, otherwise its type ("yes", "no", "default").
+func (c Connection) BranchLabel() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return c.Type
+}
+
+// The JSON methods below keep unknown fields in Extra. Each converts to a
+// local type without methods so encoding/json handles the known fields.
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *Flowchart) UnmarshalJSON(data []byte) error {
+	type plain Flowchart
+	return unmarshalKeepingExtra(data, (*plain)(f), &f.Extra)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f Flowchart) MarshalJSON() ([]byte, error) {
+	type plain Flowchart
+	return marshalWithExtra(plain(f), f.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *Overview) UnmarshalJSON(data []byte) error {
+	type plain Overview
+	return unmarshalKeepingExtra(data, (*plain)(o), &o.Extra)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o Overview) MarshalJSON() ([]byte, error) {
+	type plain Overview
+	return marshalWithExtra(plain(o), o.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	type plain Node
+	return unmarshalKeepingExtra(data, (*plain)(n), &n.Extra)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n Node) MarshalJSON() ([]byte, error) {
+	type plain Node
+	return marshalWithExtra(plain(n), n.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Connection) UnmarshalJSON(data []byte) error {
+	type plain Connection
+	return unmarshalKeepingExtra(data, (*plain)(c), &c.Extra)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Connection) MarshalJSON() ([]byte, error) {
+	type plain Connection
+	return marshalWithExtra(plain(c), c.Extra)
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// migrations[v] upgrades a document from version v to v+1. Documents are
+// decoded generically, so a migration can rename or reshape fields that
+// the typed model no longer has.
+var migrations = []func(doc map[string]any) error{
+	migrateV0,
+}
+
+// Migrate upgrades doc in place to SchemaVersion and returns the version
+// it was written with. A document without schema_version is version 0.
+func Migrate(doc map[string]any) (int, error) {
+	from, err := versionOf(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > SchemaVersion {
+		return from, fmt.Errorf("schema_version %d was written by a newer Nodey (this one reads up to %d)", from, SchemaVersion)
+	}
+	for v := from; v < SchemaVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return from, fmt.Errorf("migrating from schema_version %d: %w", v, err)
+		}
+	}
+	doc["schema_version"] = SchemaVersion
+	return from, nil
+}
+
+func versionOf(doc map[string]any) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := raw.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("schema_version must be a number, got %v", raw)
+	}
+	v, err := n.Int64()
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid schema_version %s", n)
+	}
+	return int(v), nil
+}
+
+// migrateV0 upgrades files written before the format was versioned,
+// including the legacy flowchart.json. Models of that time sometimes
+// emitted numeric ids, fractional coordinates, capitalized types and
+// connections without a type.
+func migrateV0(doc map[string]any) error {
+	nodes, _ := doc["nodes"].([]any)
+	for _, n := range nodes {
+		node, ok := n.(map[string]any)
+		if !ok {
+			continue
+		}
+		stringify(node, "id")
+		round(node, "x")
+		round(node, "y")
+		lower(node, "type")
+	}
+	conns, _ := doc["connections"].([]any)
+	for _, c := range conns {
+		conn, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		stringify(conn, "from")
+		stringify(conn, "to")
+		lower(conn, "type")
+		if t, _ := conn["type"].(string); t == "" {
+			conn["type"] = "out"
+		}
+	}
+	return nil
+}
+
+// stringify turns a numeric field into its decimal string.
+func stringify(m map[string]any, key string) {
+	if n, ok := m[key].(json.Number); ok {
+		m[key] = n.String()
+	}
+}
+
+// round turns a fractional number into the nearest integer.
+func round(m map[string]any, key string) {
+	n, ok := m[key].(json.Number)
+	if !ok {
+		return
+	}
+	if _, err := n.Int64(); err == nil {
+		return
+	}
+	if f, err := n.Float64(); err == nil {
+		m[key] = json.Number(strconv.FormatInt(int64(math.Round(f)), 10))
+	}
+}
+
+func lower(m map[string]any, key string) {
+	if s, ok := m[key].(string); ok {
+		m[key] = strings.ToLower(strings.TrimSpace(s))
+	}
+}
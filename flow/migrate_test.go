@@ -0,0 +1,130 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateV0(t *testing.T) {
+	fc, from, err := Load(filepath.Join("testdata", "v0_flowchart.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 {
+		t.Errorf("read as version %d, want 0", from)
+	}
+
+	wantNodes := []struct {
+		id, typ string
+		x, y    int
+	}{
+		{"1", "start", 121, 40},
+		{"2", "decision", 1, -8},
+		{"3", "end", 300, 0},
+		{"4", "action", 0, 0},
+	}
+	if len(fc.Nodes) != len(wantNodes) {
+		t.Fatalf("got %d nodes, want %d", len(fc.Nodes), len(wantNodes))
+	}
+	for i, want := range wantNodes {
+		n := fc.Nodes[i]
+		if n.ID != want.id || n.Type != want.typ || n.X != want.x || n.Y != want.y {
+			t.Errorf("node %d = {%s %s %d,%d}, want %+v", i, n.ID, n.Type, n.X, n.Y, want)
+		}
+	}
+
+	var conns []string
+	for _, c := range fc.Connections {
+		conns = append(conns, c.From+">"+c.To+":"+c.Type)
+	}
+	if got, want := strings.Join(conns, " "), "1>2:out 2>3:yes 2>4:no 4>3:out"; got != want {
+		t.Errorf("connections are %q, want %q", got, want)
+	}
+}
+
+func TestMigrateKeepsUnknownFields(t *testing.T) {
+	fc, _, err := Load(filepath.Join("testdata", "v0_flowchart.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for what, got := range map[string]json.RawMessage{
+		"flow layout":       fc.Extra["layout"],
+		"overview author":   fc.Overview.Extra["author"],
+		"node color":        fc.Nodes[0].Extra["color"],
+		"connection weight": fc.Connections[1].Extra["weight"],
+	} {
+		if got == nil {
+			t.Errorf("%s was dropped", what)
+		}
+	}
+	if string(fc.Connections[1].Extra["weight"]) != "0.25" {
+		t.Errorf("weight is %s, want it exactly as written", fc.Connections[1].Extra["weight"])
+	}
+
+	// Saving and loading again changes nothing.
+	data, err := Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, from, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != SchemaVersion {
+		t.Errorf("saved file reads as version %d, want %d", from, SchemaVersion)
+	}
+	fc.SchemaVersion = SchemaVersion
+	if !reflect.DeepEqual(again, fc) {
+		t.Errorf("round trip changed the flow:\n%+v\n%+v", again, fc)
+	}
+	data2, err := Marshal(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Errorf("second save differs:\n%s\n%s", data, data2)
+	}
+}
+
+func TestExtraRoundTrip(t *testing.T) {
+	in := `{"schema_version":1,"overview":{"title":"T","summary":"S","tags":["a"]},"nodes":[{"id":"n1","type":"start","x":0,"y":0,"title":"Go","notes":"","subflow":{"ref":"child_flow.json","pinned":true},"zz":{"k":1}}],"connections":[],"future":null}`
+	fc, from, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Errorf("read as version %d, want 1", from)
+	}
+	out, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("round trip changed the document:\n got %s\nwant %s", out, in)
+	}
+}
+
+func TestMigrateRejectsNewerVersions(t *testing.T) {
+	_, from, err := Parse([]byte(`{"schema_version": 99, "nodes": []}`))
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("got %v, want an error about a newer version", err)
+	}
+	if from != 99 {
+		t.Errorf("reported version %d, want 99", from)
+	}
+	if _, _, err := Parse([]byte(`{"schema_version": "one"}`)); err == nil {
+		t.Error("a non-numeric schema_version should be an error")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, _, err := Load(filepath.Join(t.TempDir(), "nope_flow.json"))
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, want a not-exist error", err)
+	}
+}
@@ -0,0 +1,53 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// Subflow is the child flow a "subprocess" node stands for: another flow
+// file (Ref) or a flow embedded in the node (Flow). Exactly one is set.
+type Subflow struct {
+	// Ref is the path of a flow file, relative to the directory of the
+	// flow that references it.
+	Ref string `json:"ref,omitempty"`
+
+	Flow *Flowchart `json:"flow,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Child returns the flow s stands for, loading Ref relative to dir.
+func (s Subflow) Child(dir string) (Flowchart, error) {
+	switch {
+	case s.Ref != "" && s.Flow != nil:
+		return Flowchart{}, fmt.Errorf("subflow has both a ref and an embedded flow")
+	case s.Flow != nil:
+		return *s.Flow, nil
+	case s.Ref == "":
+		return Flowchart{}, fmt.Errorf("subflow has neither a ref nor an embedded flow")
+	}
+	fc, _, err := Load(s.Path(dir))
+	return fc, err
+}
+
+// Path is the file Ref points at when it is resolved against dir.
+func (s Subflow) Path(dir string) string {
+	if s.Ref == "" || filepath.IsAbs(s.Ref) {
+		return s.Ref
+	}
+	return filepath.Join(dir, s.Ref)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Subflow) UnmarshalJSON(data []byte) error {
+	type plain Subflow
+	return unmarshalKeepingExtra(data, (*plain)(s), &s.Extra)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Subflow) MarshalJSON() ([]byte, error) {
+	type plain Subflow
+	return marshalWithExtra(plain(s), s.Extra)
+}
@@ -0,0 +1,159 @@
+package flow
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NodeType describes a kind of node: what it means, for the Architect, and
+// how it is drawn, for the generator. Width and Height are the size in
+// pixels used both to lay the flow out and to draw the node.
+type NodeType struct {
+	Name        string `json:"name"`
+	Shape       string `json:"shape"`
+	Color       string `json:"color"` // CSS color of the outline
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Shapes are the shapes a node can be drawn as, with their default size.
+var Shapes = map[string][2]int{
+	"pill":          {140, 60}, // rounded ends
+	"rounded":       {180, 80}, // rectangle with rounded corners
+	"rect":          {180, 80}, // square corners
+	"double":        {180, 80}, // rectangle with a double outline
+	"circle":        {70, 70},
+	"diamond":       {140, 140},
+	"flag":          {180, 70}, // pentagon pointing in the direction of flow
+	"subroutine":    {180, 80}, // rectangle with a bar at each side
+	"cylinder":      {140, 90}, // database
+	"document":      {170, 90}, // wavy bottom edge
+	"manual":        {180, 80}, // top edge slanted up to the right
+	"parallelogram": {180, 70},
+	"delay":         {150, 70}, // D shape
+	"hexagon":       {180, 80},
+	"bar":           {200, 14}, // thick line, label shown on hover
+	"note":          {180, 60}, // open bracket, no fill
+}
+
+// builtinTypes are the node types every flow can use.
+var builtinTypes = []NodeType{
+	{Name: "start", Shape: "pill", Color: "#F472B6", Description: "The entry point of the flow."},
+	{Name: "trigger", Shape: "flag", Color: "#14B8A6", Description: "The event that initiates a process logic (e.g. a webhook, a cron tick)."},
+	{Name: "action", Shape: "rounded", Color: "#3B82F6", Description: "A process step."},
+	{Name: "decision", Shape: "diamond", Color: "#F59E0B", Description: "Branching point."},
+	{Name: "subprocess", Shape: "subroutine", Color: "#8B5CF6", Description: "A step that runs a child flow."},
+	{Name: "datastore", Shape: "cylinder", Color: "#0EA5E9", Description: "A database or other data store that is read or written."},
+	{Name: "document", Shape: "document", Color: "#6366F1", Description: "A document or report that is produced or read."},
+	{Name: "manual_input", Shape: "manual", Color: "#EC4899", Description: "Data a person enters by hand (e.g. a form)."},
+	{Name: "io", Shape: "parallelogram", Color: "#22C55E", Description: "Input or output, such as reading a file or sending a message."},
+	{Name: "delay", Shape: "delay", Color: "#F97316", Description: "A wait, timer or timeout."},
+	{Name: "fork", Shape: "bar", Color: "#334155", Description: "Splits the flow into parallel paths; at least two outgoing connections."},
+	{Name: "join", Shape: "bar", Color: "#334155", Description: "Waits for parallel paths to finish; at least two incoming connections."},
+	{Name: "loop", Shape: "hexagon", Color: "#D946EF", Description: "Repeats the steps it leads to for each item or until a condition holds."},
+	{Name: "external", Shape: "double", Color: "#475569", Description: "A system outside the flow's control (e.g. a payment provider)."},
+	{Name: "annotation", Shape: "note", Color: "#94A3B8", Description: "A comment on the flow. Not a step: it needs no connections."},
+	{Name: "end", Shape: "circle", Color: "#64748B", Description: "The final step."},
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = withSizes(builtinTypes)
+)
+
+var (
+	typeName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|hsl)a?\([0-9.,%\s]+\))$`)
+)
+
+// NodeTypes returns the built-in node types followed by the registered ones.
+func NodeTypes() []NodeType {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	return append([]NodeType(nil), types...)
+}
+
+// NodeTypeNames returns the name of every node type, in NodeTypes order.
+func NodeTypeNames() []string {
+	var names []string
+	for _, t := range NodeTypes() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// LookupNodeType returns the node type called name.
+func LookupNodeType(name string) (NodeType, bool) {
+	for _, t := range NodeTypes() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return NodeType{}, false
+}
+
+// RegisterNodeType adds a custom node type. A missing size is taken from
+// its shape.
+func RegisterNodeType(t NodeType) error {
+	if err := t.Check(); err != nil {
+		return err
+	}
+	if t.Description == "" {
+		t.Description = fmt.Sprintf("A %s step.", strings.ReplaceAll(t.Name, "_", " "))
+	}
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	for _, known := range types {
+		if known.Name == t.Name {
+			return fmt.Errorf("node type %q already exists", t.Name)
+		}
+	}
+	types = append(types, withSizes([]NodeType{t})...)
+	return nil
+}
+
+// Check reports whether t can be registered. Names and colors end up in
+// the generated CSS, so both are restricted to safe forms.
+func (t NodeType) Check() error {
+	if !typeName.MatchString(t.Name) {
+		return fmt.Errorf("node type %q: name must be lowercase letters, digits and underscores", t.Name)
+	}
+	if _, ok := Shapes[t.Shape]; !ok {
+		return fmt.Errorf("node type %q: unknown shape %q, expected one of %s", t.Name, t.Shape, strings.Join(shapeNames(), ", "))
+	}
+	if !cssColor.MatchString(t.Color) {
+		return fmt.Errorf("node type %q: color %q is not a CSS color", t.Name, t.Color)
+	}
+	if t.Width < 0 || t.Height < 0 {
+		return fmt.Errorf("node type %q: size must not be negative", t.Name)
+	}
+	return nil
+}
+
+func withSizes(ts []NodeType) []NodeType {
+	out := make([]NodeType, len(ts))
+	for i, t := range ts {
+		size := Shapes[t.Shape]
+		if t.Width == 0 {
+			t.Width = size[0]
+		}
+		if t.Height == 0 {
+			t.Height = size[1]
+		}
+		out[i] = t
+	}
+	return out
+}
+
+func shapeNames() []string {
+	names := make([]string, 0, len(Shapes))
+	for name := range Shapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
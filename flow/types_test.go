@@ -0,0 +1,105 @@
+package flow
+
+import (
+	"strings"
+	"testing"
+)
+
+// resetTypes drops the node types a test registers.
+func resetTypes(t *testing.T) {
+	t.Cleanup(func() {
+		typesMu.Lock()
+		defer typesMu.Unlock()
+		types = withSizes(builtinTypes)
+	})
+}
+
+func TestLookupNodeType(t *testing.T) {
+	tests := []struct {
+		name          string
+		shape         string
+		width, height int
+	}{
+		{"start", "pill", 140, 60},
+		{"decision", "diamond", 140, 140},
+		{"datastore", "cylinder", 140, 90},
+		{"fork", "bar", 200, 14},
+		{"end", "circle", 70, 70},
+	}
+	for _, tt := range tests {
+		nt, ok := LookupNodeType(tt.name)
+		if !ok || nt.Shape != tt.shape || nt.Width != tt.width || nt.Height != tt.height {
+			t.Errorf("LookupNodeType(%q) = %+v, %v; want a %s of %dx%d", tt.name, nt, ok, tt.shape, tt.width, tt.height)
+		}
+	}
+	for _, name := range []string{"teleport", "Start", ""} {
+		if _, ok := LookupNodeType(name); ok {
+			t.Errorf("LookupNodeType(%q) found a type", name)
+		}
+	}
+	for _, nt := range NodeTypes() {
+		if _, ok := Shapes[nt.Shape]; !ok || nt.Width == 0 || nt.Height == 0 || nt.Description == "" {
+			t.Errorf("built-in type %+v is incomplete", nt)
+		}
+	}
+}
+
+func TestRegisterNodeType(t *testing.T) {
+	resetTypes(t)
+	before := len(NodeTypes())
+
+	if err := RegisterNodeType(NodeType{Name: "review_gate", Shape: "hexagon", Color: "#123abc", Height: 100}); err != nil {
+		t.Fatal(err)
+	}
+	nt, ok := LookupNodeType("review_gate")
+	if !ok {
+		t.Fatal("the registered type is not found")
+	}
+	// The width comes from the shape, the height was given.
+	if nt.Width != 180 || nt.Height != 100 || nt.Description != "A review gate step." {
+		t.Errorf("registered type is %+v", nt)
+	}
+	names := NodeTypeNames()
+	if len(names) != before+1 || names[len(names)-1] != "review_gate" {
+		t.Errorf("custom types should follow the built-in ones: %q", names)
+	}
+
+	for _, dup := range []NodeType{
+		{Name: "review_gate", Shape: "rect", Color: "red"},
+		{Name: "decision", Shape: "rect", Color: "red"},
+	} {
+		if err := RegisterNodeType(dup); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("registering %q again gave %v", dup.Name, err)
+		}
+	}
+	if len(NodeTypes()) != before+1 {
+		t.Error("a refused registration changed the types")
+	}
+}
+
+func TestNodeTypeCheck(t *testing.T) {
+	tests := []struct {
+		nt   NodeType
+		want string // "" means valid
+	}{
+		{NodeType{Name: "gate", Shape: "rect", Color: "#fff"}, ""},
+		{NodeType{Name: "gate2", Shape: "rect", Color: "rgba(0, 0, 0, .5)"}, ""},
+		{NodeType{Name: "gate", Shape: "rect", Color: "teal"}, ""},
+		{NodeType{Name: "Gate", Shape: "rect", Color: "red"}, "name must be lowercase"},
+		{NodeType{Name: "gate-x", Shape: "rect", Color: "red"}, "name must be lowercase"},
+		{NodeType{Name: "", Shape: "rect", Color: "red"}, "name must be lowercase"},
+		{NodeType{Name: "gate", Shape: "star", Color: "red"}, `unknown shape "star"`},
+		{NodeType{Name: "gate", Shape: "rect", Color: "red;} body{display:none"}, "is not a CSS color"},
+		{NodeType{Name: "gate", Shape: "rect", Color: "url(x)"}, "is not a CSS color"},
+		{NodeType{Name: "gate", Shape: "rect", Color: "red", Width: -1}, "size must not be negative"},
+	}
+	for _, tt := range tests {
+		err := tt.nt.Check()
+		if tt.want == "" && err != nil {
+			t.Errorf("%+v: %v", tt.nt, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%+v: got %v, want %q", tt.nt, err, tt.want)
+		}
+	}
+}
@@ -4,11 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DN-OpenSource/nodey/flow"
 )
 
-// GenerateHTML writes the flowchart JSON into a template HTML file and saves the raw JSON.
-func GenerateHTML(flowchartData interface{}, filename string) error {
-	jsonData, err := json.MarshalIndent(flowchartData, "", "  ")
+// GenerateHTML writes the flowchart into a template HTML file and saves the raw JSON next to it.
+// Subflows that refer to other flow files are embedded in the HTML so the
+// viewer can open them; the JSON keeps the references.
+func GenerateHTML(fc flow.Flowchart, filename string) error {
+	jsonData, err := flow.Marshal(fc)
+	if err != nil {
+		return err
+	}
+	viewData, err := flow.Marshal(inlineSubflows(fc, filepath.Dir(filename), map[string]bool{}))
+	if err != nil {
+		return err
+	}
+	types := flow.NodeTypes()
+	typesData, err := json.Marshal(types)
 	if err != nil {
 		return err
 	}
@@ -36,19 +51,6 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
             --grid-line: #E2E8F0;
             --text-main: #1E293B;
             --white: #FFFFFF;
-            
-            /* Node Colors */
-            --start-border: #F472B6; 
-            --start-shadow: rgba(244, 114, 182, 0.25);
-            
-            --action-border: #3B82F6;
-            --action-shadow: rgba(59, 130, 246, 0.2);
-            
-            --decision-border: #F59E0B;
-            --decision-shadow: rgba(245, 158, 11, 0.2);
-            
-            --end-border: #64748B;
-            --end-shadow: rgba(100, 116, 139, 0.2);
         }
 
         body {
@@ -105,57 +107,125 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
             box-shadow: 0 20px 25px -5px rgba(0,0,0,0.1), 0 10px 10px -5px rgba(0,0,0,0.04);
         }
 
-        /* Type: Start (Pill) */
-        .node.type-start {
-            width: 140px;
-            height: 60px;
-            border-radius: 9999px;
-            border: 2px solid var(--start-border);
-            background: #FDF2F8; /* Subtle tint */
+        /* Shapes. Every node type has a shape; its size and --node-color
+           come from the per-type rules at the end, generated from the node
+           type registry. */
+        .node {
+            --node-tint: color-mix(in srgb, var(--node-color) 8%%, white);
+            box-sizing: border-box;
+            border: 2px solid var(--node-color);
+            background: var(--node-tint);
         }
-
-        /* Type: Action (Rounded Rect) */
-        .node.type-action {
-            width: 180px;
-            height: 80px;
-            border-radius: 12px;
-            border: 2px solid var(--action-border);
-            padding: 0 10px;
+        .node-label {
+            position: relative;
+            z-index: 1;
+            padding: 0 12px;
+            overflow: hidden;
         }
-
-        /* Type: Decision (Diamond Wrapper) */
-        .node.type-decision-wrap {
-            width: 140px; 
-            height: 140px;
+        .shape-pill { border-radius: 9999px; }
+        .shape-rounded { border-radius: 12px; }
+        .shape-rect { border-radius: 2px; }
+        .shape-double { border: 5px double var(--node-color); border-radius: 2px; }
+        .shape-circle { border: 3px double var(--node-color); border-radius: 50%%; font-size: 12px; }
+        .shape-circle .node-label { padding: 0 4px; }
+        .shape-bar { background: var(--node-color); border-radius: 4px; }
+        .shape-bar .node-label { display: none; } /* shown as a tooltip */
+        .shape-note {
             background: none;
             box-shadow: none;
+            border-right: none;
+            border-radius: 0;
+            justify-content: flex-start;
+            text-align: left;
+            font-weight: 500;
+            font-style: italic;
+            color: #475569;
+        }
+        .shape-note:hover { box-shadow: none; }
+
+        /* Outlines a CSS box cannot draw are an SVG path behind the label */
+        .node.svg-shape, .node.svg-shape:hover {
+            background: none;
             border: none;
+            box-shadow: none;
         }
-        .diamond-shape {
-            position: absolute;
-            width: 100px; /* Sqrt(140^2/2) approx */
-            height: 100px;
-            background: var(--white);
-            border: 2px solid var(--decision-border);
-            transform: rotate(45deg);
+        .node svg.shape {
             z-index: 0;
-            box-shadow: 0 10px 15px -3px rgba(245, 158, 11, 0.15);
+            filter: drop-shadow(0 6px 8px rgba(0,0,0,0.08));
         }
-        .decision-text {
-            position: relative;
-            z-index: 1;
-            max-width: 120px;
+        .node svg.shape path {
+            fill: var(--node-tint);
+            stroke: var(--node-color);
+            stroke-width: 2px;
+        }
+        .shape-diamond .node-label { max-width: 100px; padding: 0; font-size: 13px; }
+        .shape-subroutine .node-label { padding: 0 20px; }
+
+        /* Breadcrumbs */
+        #breadcrumbs {
+            display: none;
+            position: fixed;
+            top: 24px; left: 50%%;
+            transform: translateX(-50%%);
+            padding: 10px 16px;
+            gap: 8px;
+            background: var(--white);
+            border: 1px solid #E2E8F0;
+            border-radius: 12px;
+            box-shadow: 0 10px 15px -3px rgba(0,0,0,0.1);
             font-size: 13px;
+            font-weight: 600;
+            z-index: 100;
         }
+        .crumb { color: #3B82F6; cursor: pointer; }
+        .crumb:hover { text-decoration: underline; }
+        .crumb + .crumb::before { content: '\203A'; margin-right: 8px; color: #94A3B8; }
+        .crumb.current { color: #0F172A; cursor: default; text-decoration: none; }
 
-        /* Type: End (Circle) */
-        .node.type-end {
-            width: 70px; 
-            height: 70px;
-            border-radius: 50%%;
-            border: 3px double var(--end-border);
-            background: #F8FAFC;
+        /* Swimlanes */
+        .lane {
+            position: absolute;
+            box-sizing: border-box;
+            border-right: 1px dashed #CBD5E1;
+            z-index: 0;
+        }
+        .lane.rows {
+            border-right: none;
+            border-bottom: 1px dashed #CBD5E1;
         }
+        .lane:nth-child(odd) { background: rgba(226, 232, 240, 0.35); }
+        .lane-title {
+            padding: 14px 16px;
+            font-size: 12px;
+            font-weight: 700;
+            letter-spacing: 0.05em;
+            text-transform: uppercase;
+            color: #64748B;
+            white-space: nowrap;
+        }
+        .lane.rows .lane-title {
+            position: absolute;
+            left: 0; top: 0; bottom: 0;
+            writing-mode: vertical-rl;
+            transform: rotate(180deg);
+            text-align: center;
+            padding: 16px 14px;
+        }
+        #lane-toggle {
+            display: none;
+            position: fixed;
+            top: 24px; left: 32px;
+            padding: 10px 14px;
+            background: var(--white);
+            border: 1px solid #E2E8F0;
+            border-radius: 12px;
+            box-shadow: 0 10px 15px -3px rgba(0,0,0,0.1);
+            font: 700 12px 'Inter', sans-serif;
+            color: #475569;
+            cursor: pointer;
+            z-index: 100;
+        }
+        #lane-toggle:hover { background: #F1F5F9; color: #0F172A; }
 
         /* SVG Connections */
         svg {
@@ -184,6 +254,7 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
             font-weight: 700;
             fill: #64748B;
             text-anchor: middle;
+            pointer-events: visiblePainted; /* hover shows the branch condition */
         }
 
         /* Sidebar Inspector */
@@ -228,6 +299,8 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
             font-size: 12px;
             border: 1px solid #334155;
         }
+        .ins-sources-title { margin-top: 16px; }
+        .ins-sources { margin: 0; padding-left: 18px; font-family: 'Menlo', monospace; font-size: 12px; word-break: break-all; }
 
         /* Zoom Controls */
         #zoom-controls {
@@ -262,12 +335,15 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
             background: #F8FAFC;
         }
 
+        /* Node types */
+%s
     </style>
 </head>
 <body>
 
     <div id="viewport">
         <div id="canvas">
+            <div id="lanes"></div>
             <svg id="lines">
                 <defs>
                     <marker id="arrow" markerWidth="10" markerHeight="10" refX="9" refY="3" orient="auto" markerUnits="strokeWidth">
@@ -279,6 +355,9 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
                     <marker id="arrow-no" markerWidth="10" markerHeight="10" refX="9" refY="3" orient="auto">
                          <path d="M0,0 L0,6 L9,3 z" fill="#EF4444" />
                     </marker>
+                    <marker id="arrow-branch" markerWidth="10" markerHeight="10" refX="9" refY="3" orient="auto">
+                         <path d="M0,0 L0,6 L9,3 z" fill="#F59E0B" />
+                    </marker>
                 </defs>
             </svg>
             <div id="nodes"></div>
@@ -293,6 +372,9 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
         <div class="ins-body" id="ins-body">Select a node...</div>
     </div>
 
+    <div id="breadcrumbs"></div>
+    <button id="lane-toggle">Lanes: columns</button>
+
     <div id="zoom-controls">
         <button class="zoom-btn" onclick="updateZoom(0.1)">+</button>
         <div id="zoom-val">100%%</div>
@@ -302,158 +384,346 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
     <script>
         const data = %s;
 
-        // Init Dagre
-        const g = new dagre.graphlib.Graph();
-        g.setGraph({ 
-            rankdir: 'TB', 
-            nodesep: 140, // Horizontal spacing
-            ranksep: 120, // Vertical spacing
-            edgesep: 50
-        });
-        g.setDefaultEdgeLabel(function() { return {}; });
-
-        // Add Nodes
-        data.nodes.forEach(node => {
-            let w = 180, h = 80;
-            if (node.type === 'start') { w = 140; h = 60; }
-            if (node.type === 'end') { w = 70; h = 70; }
-            if (node.type === 'decision') { w = 140; h = 140; }
-            
-            g.setNode(node.id, { label: node.title, width: w, height: h, type: node.type, notes: node.notes });
-        });
+        // Node types: name -> shape, color and size. The size here and in the
+        // CSS rules above come from the same registry. Unknown types are
+        // drawn as actions.
+        const nodeTypes = {};
+        %s.forEach(t => { nodeTypes[t.name] = t; });
+        const typeOf = name => nodeTypes[name] || nodeTypes.action;
+
+        // Outline of the shapes CSS cannot draw, as an SVG path in a w x h box
+        function shapePath(shape, w, h) {
+            const s = 1; // half the stroke, so the outline stays in the box
+            const k = Math.min(20, w / 6);
+            const r = Math.min(12, h / 6);
+            switch (shape) {
+                case 'diamond': return ['M', w/2, s, 'L', w-s, h/2, 'L', w/2, h-s, 'L', s, h/2, 'Z'];
+                case 'flag': return ['M', s, s, 'H', w-k, 'L', w-s, h/2, 'L', w-k, h-s, 'H', s, 'Z'];
+                case 'subroutine': return ['M', s, s, 'H', w-s, 'V', h-s, 'H', s, 'Z', 'M', 12, s, 'V', h-s, 'M', w-12, s, 'V', h-s];
+                case 'cylinder': return ['M', s, r, 'A', w/2-s, r, 0, 0, 1, w-s, r, 'V', h-r, 'A', w/2-s, r, 0, 0, 1, s, h-r, 'Z',
+                                         'M', s, r, 'A', w/2-s, r, 0, 0, 0, w-s, r];
+                case 'document': return ['M', s, s, 'H', w-s, 'V', h-r, 'C', w*0.75, h-3*r, w*0.25, h+r, s, h-r, 'Z'];
+                case 'manual': return ['M', s, h*0.3, 'L', w-s, s, 'V', h-s, 'H', s, 'Z'];
+                case 'parallelogram': return ['M', k, s, 'H', w-s, 'L', w-k, h-s, 'H', s, 'Z'];
+                case 'delay': return ['M', s, s, 'H', w-h/2, 'A', h/2-s, h/2-s, 0, 0, 1, w-h/2, h-s, 'H', s, 'Z'];
+                case 'hexagon': return ['M', k, s, 'H', w-k, 'L', w-s, h/2, 'L', w-k, h-s, 'H', k, 'L', s, h/2, 'Z'];
+            }
+            return null;
+        }
 
-        // Add Edges
-        data.connections.forEach(conn => {
-            g.setEdge(conn.from, conn.to, { type: conn.type });
-        });
+        // Subflows: path is the chain of flows from the root to the one on
+        // screen, shown as breadcrumbs.
+        const path = [{ title: data.overview.title, flow: data }];
+        let current = data;
 
-        // Layout
-        dagre.layout(g);
+        let lanes = [];
+        let laneRows = false; // false: lanes are columns and the flow runs top to bottom; true: rows, left to right
 
-        // Render
         const nodesDiv = document.getElementById('nodes');
         const linesSvg = document.getElementById('lines');
-
-        g.nodes().forEach(id => {
-            const n = g.node(id);
-            const el = document.createElement('div');
-            el.id = 'node-' + id;
+        const lanesDiv = document.getElementById('lanes');
+        let g;
+
+        function render() {
+            // Swimlanes: one band per actor, in order. Nodes whose lane is not
+            // listed go to a trailing unnamed band.
+            lanes = (current.lanes || []).slice();
+            if (lanes.length && current.nodes.some(n => lanes.indexOf(n.lane) < 0)) lanes.push('');
+
+            // Init Dagre
+            // Multigraph: a decision may send several branches to the same node
+            g = new dagre.graphlib.Graph({ multigraph: true });
+            g.setGraph({ 
+                rankdir: (lanes.length && laneRows) ? 'LR' : 'TB', 
+                nodesep: 140, // Horizontal spacing
+                ranksep: 120, // Vertical spacing
+                edgesep: 50
+            });
+            g.setDefaultEdgeLabel(function() { return {}; });
+
+            // Add Nodes
+            current.nodes.forEach(node => {
+                const spec = typeOf(node.type);
+                g.setNode(node.id, { label: node.title, width: spec.width, height: spec.height, type: node.type, spec: spec, notes: node.notes, lane: node.lane || '', sources: node.sources || [], subflow: node.subflow || null });
+            });
+
+            // Add Edges
+            // Every connection with a name gets a label; Dagre reserves room for it
+            current.connections.forEach((conn, i) => {
+                let label = conn.label || '';
+                if (!label && (conn.type === 'yes' || conn.type === 'no' || conn.type === 'default')) label = conn.type;
+                const edge = { type: conn.type, label: label, condition: conn.condition || '' };
+                if (label) {
+                    edge.width = label.length * 7 + 16;
+                    edge.height = 20;
+                    edge.labelpos = 'c';
+                }
+                g.setEdge(conn.from, conn.to, edge, 'c' + i);
+            });
+
+            // Layout
+            dagre.layout(g);
+            if (lanes.length) layoutLanes();
+
+            // Render
+            nodesDiv.innerHTML = '';
+            lanesDiv.innerHTML = '';
+            linesSvg.querySelectorAll(':scope > :not(defs)').forEach(el => el.remove());
+
+            g.nodes().forEach(id => {
+                const n = g.node(id);
+                const el = document.createElement('div');
+                el.id = 'node-' + id;
             
-            if (n.type === 'decision') {
-                el.className = 'node type-decision-wrap';
-                el.innerHTML = '<div class="diamond-shape"></div><div class="decision-text">' + n.label + '</div>';
-            } else {
-                el.className = 'node type-' + n.type;
-                el.innerText = n.label;
-            }
-
-            // Center using top/left minus half width/height
-            const x = n.x - n.width/2;
-            const y = n.y - n.height/2;
+                el.className = 'node shape-' + n.spec.shape + ' type-' + n.spec.name;
+                const outline = shapePath(n.spec.shape, n.width, n.height);
+                if (outline) {
+                    el.className += ' svg-shape';
+                    el.innerHTML = '<svg class="shape" viewBox="0 0 ' + n.width + ' ' + n.height + '"><path d="' + outline.join(' ') + '"/></svg>';
+                }
+                const label = document.createElement('div');
+                label.className = 'node-label';
+                label.innerText = n.label;
+                el.appendChild(label);
+                el.title = n.label;
+
+                // Center using top/left minus half width/height
+                const x = n.x - n.width/2;
+                const y = n.y - n.height/2;
             
-            el.style.left = x + 'px';
-            el.style.top = y + 'px';
-            el.dataset.title = n.label;
-            el.dataset.notes = n.notes || '';
-            el.dataset.type = n.type;
+                el.style.left = x + 'px';
+                el.style.top = y + 'px';
+                el.dataset.title = n.label;
+                el.dataset.notes = n.notes || '';
+                el.dataset.sources = n.sources.join('\n');
+                el.dataset.type = n.type;
             
-            el.onclick = (e) => {
-                e.stopPropagation();
-                showInspector(el.dataset);
-            };
-
-            nodesDiv.appendChild(el);
-        });
-
-        g.edges().forEach(e => {
-            const edge = g.edge(e);
-            // Dagre gives points
-            const points = edge.points;
+                el.onclick = (e) => {
+                    e.stopPropagation();
+                    if (n.type === 'subprocess') openSubflow(n);
+                    else showInspector(el.dataset);
+                };
+
+                nodesDiv.appendChild(el);
+            });
+
+            const branchColors = { yes: '#10B981', no: '#EF4444', branch: '#F59E0B', default: '#94A3B8' };
+            g.edges().forEach(e => {
+                const edge = g.edge(e);
+                // Dagre gives points
+                const points = edge.points;
             
-            let d = "M " + points[0].x + " " + points[0].y;
-            // Use smooth curve through points
-            // Simple Catmull-Rom or Cubic Bezier logic?
-            // Dagre points usually are distinct control points in polyline
-            // Let's create a smooth curve using simplified logic or just polyline with rounded corners
+                let d = "M " + points[0].x + " " + points[0].y;
+                // Use smooth curve through points
+                // Simple Catmull-Rom or Cubic Bezier logic?
+                // Dagre points usually are distinct control points in polyline
+                // Let's create a smooth curve using simplified logic or just polyline with rounded corners
             
-            // For true smooth curves, we can use the points as L commands but rounded.
-            // Or better: cubic bezier. 
-            // Simple robust approach: Straight lines with rounded corners or just straight for cleanliness.
-            // User requested "clean".
+                // For true smooth curves, we can use the points as L commands but rounded.
+                // Or better: cubic bezier. 
+                // Simple robust approach: Straight lines with rounded corners or just straight for cleanliness.
+                // User requested "clean".
             
-            // Let's try smooth cubic from start to end with control points from dagre? No dagre gives waypoints.
-            // Let's use a mapping of points to svg command.
+                // Let's try smooth cubic from start to end with control points from dagre? No dagre gives waypoints.
+                // Let's use a mapping of points to svg command.
             
-            if (points.length > 2) {
-                 // Curve through middle points
-                 // Using 'C' if possible? No, 'L' is safer for now, maybe rounded joins.
-                 // To make it look "Great" let's try a smoothing function.
+                if (points.length > 2) {
+                     // Curve through middle points
+                     // Using 'C' if possible? No, 'L' is safer for now, maybe rounded joins.
+                     // To make it look "Great" let's try a smoothing function.
                  
-                 // Render as simple polyline for clean "Engineering" look, or basic curve.
-                 // Let's try a simple Bezier roughly following the path.
-                 // Actually, standard orthogonal/curved library lines look best.
-                 // We will simply draw lines to intermediate points.
+                     // Render as simple polyline for clean "Engineering" look, or basic curve.
+                     // Let's try a simple Bezier roughly following the path.
+                     // Actually, standard orthogonal/curved library lines look best.
+                     // We will simply draw lines to intermediate points.
                  
-                 for(let i=1; i<points.length; i++) {
-                     d += " L " + points[i].x + " " + points[i].y;
-                 }
-            } else {
-                 d += " L " + points[1].x + " " + points[1].y;
-            }
-
-            const path = document.createElementNS("http://www.w3.org/2000/svg", "path");
-            path.setAttribute("d", d);
-            path.setAttribute("stroke", "#94A3B8");
+                     for(let i=1; i<points.length; i++) {
+                         d += " L " + points[i].x + " " + points[i].y;
+                     }
+                } else {
+                     d += " L " + points[1].x + " " + points[1].y;
+                }
+
+                const path = document.createElementNS("http://www.w3.org/2000/svg", "path");
+                path.setAttribute("d", d);
+                path.setAttribute("stroke", "#94A3B8");
             
-            // Marker / Color
-            let c = edge.type;
-            if (c === 'yes') {
-                path.setAttribute("stroke", "#10B981");
-                path.setAttribute("marker-end", "url(#arrow-yes)");
-            } else if (c === 'no') {
-                path.setAttribute("stroke", "#EF4444");
-                path.setAttribute("marker-end", "url(#arrow-no)");
-            } else {
-                path.setAttribute("marker-end", "url(#arrow)");
-            }
+                // Marker / Color
+                let c = edge.type;
+                const color = branchColors[c] || '#94A3B8';
+                path.setAttribute("stroke", color);
+                if (g.node(e.v).type === 'annotation' || g.node(e.w).type === 'annotation') {
+                    path.setAttribute("stroke-dasharray", "2 5"); // an association, not a step
+                } else if (c === 'yes' || c === 'no' || c === 'branch') {
+                    path.setAttribute("marker-end", "url(#arrow-" + c + ")");
+                } else {
+                    path.setAttribute("marker-end", "url(#arrow)");
+                }
+                if (c === 'default') path.setAttribute("stroke-dasharray", "6 4");
             
-            // Add slight curve radius to CSS 'stroke-linejoin: round' takes care of corners visually
-            linesSvg.appendChild(path);
-
-            // Labels for decision
-            if (c === 'yes' || c === 'no') {
-                // Determine label pos - usually near first 25% of path or points[0]
-                const lp = points[0]; // Start
-                const np = points[1] || points[0];
-                
-                const txt = document.createElementNS("http://www.w3.org/2000/svg", "text");
-                // Offset slightly
-                txt.setAttribute("x", (lp.x + np.x)/2 + 10);
-                txt.setAttribute("y", (lp.y + np.y)/2);
-                txt.textContent = c.toUpperCase();
-                txt.setAttribute("class", "edge-label");
-                if(c==='yes') txt.setAttribute("fill", "#10B981");
-                else txt.setAttribute("fill", "#EF4444");
-                
-                // Background for text (optional, simpler not to collide)
-                linesSvg.appendChild(txt);
+                // Add slight curve radius to CSS 'stroke-linejoin: round' takes care of corners visually
+                linesSvg.appendChild(path);
+
+                // Label every named connection at the spot Dagre reserved for it
+                if (edge.label) {
+                    const txt = document.createElementNS("http://www.w3.org/2000/svg", "text");
+                    txt.setAttribute("x", edge.x);
+                    txt.setAttribute("y", edge.y + 4);
+                    txt.textContent = (c === 'yes' || c === 'no') ? edge.label.toUpperCase() : edge.label;
+                    txt.setAttribute("class", "edge-label");
+                    txt.setAttribute("fill", c === 'out' ? '#64748B' : color);
+                    if (edge.condition) {
+                        // Hover shows when the branch is taken
+                        const tip = document.createElementNS("http://www.w3.org/2000/svg", "title");
+                        tip.textContent = edge.condition;
+                        txt.appendChild(tip);
+                    }
+
+                    const bg = document.createElementNS("http://www.w3.org/2000/svg", "rect");
+                    bg.setAttribute("class", "edge-label-bg");
+                    bg.setAttribute("x", edge.x - edge.width/2);
+                    bg.setAttribute("y", edge.y - edge.height/2);
+                    bg.setAttribute("width", edge.width);
+                    bg.setAttribute("height", edge.height);
+                    bg.setAttribute("rx", 6);
+                    linesSvg.appendChild(bg);
+                    linesSvg.appendChild(txt);
+                }
+            });
+        }
+
+        // layoutLanes keeps Dagre's ranks (the flow direction) but moves every
+        // node across into its lane's band, then reroutes the edges.
+        function layoutLanes() {
+            const LANE_HEADER = 48, LANE_PAD = 40, LANE_MIN = 220, GAP = 60;
+            const rankAxis = laneRows ? 'x' : 'y', crossAxis = laneRows ? 'y' : 'x';
+            const rankSize = laneRows ? 'width' : 'height', crossSize = laneRows ? 'height' : 'width';
+            const pt = (r, c) => laneRows ? { x: r, y: c } : { x: c, y: r };
+
+            // Group each lane's nodes by rank, keeping Dagre's order within a rank
+            const byLane = lanes.map(() => ({}));
+            let extent = 0;
+            g.nodes().forEach(id => {
+                const n = g.node(id);
+                let i = lanes.indexOf(n.lane);
+                if (i < 0) i = lanes.length - 1;
+                const rank = Math.round(n[rankAxis]);
+                (byLane[i][rank] = byLane[i][rank] || []).push(n);
+                n[rankAxis] += LANE_HEADER;
+                extent = Math.max(extent, n[rankAxis] + n[rankSize]/2);
+            });
+
+            let offset = 0;
+            byLane.forEach((ranks, i) => {
+                const rows = Object.values(ranks);
+                const span = row => row.reduce((sum, n) => sum + n[crossSize], 0) + GAP * (row.length - 1);
+                let size = LANE_MIN;
+                rows.forEach(row => { size = Math.max(size, span(row) + 2 * LANE_PAD); });
+                rows.forEach(row => {
+                    row.sort((a, b) => a[crossAxis] - b[crossAxis]);
+                    let pos = offset + (size - span(row)) / 2;
+                    row.forEach(n => { n[crossAxis] = pos + n[crossSize]/2; pos += n[crossSize] + GAP; });
+                });
+
+                const band = document.createElement('div');
+                band.className = 'lane' + (laneRows ? ' rows' : '');
+                const box = laneRows
+                    ? { left: 0, top: offset, width: extent + LANE_PAD, height: size }
+                    : { left: offset, top: 0, width: size, height: extent + LANE_PAD };
+                Object.keys(box).forEach(k => { band.style[k] = box[k] + 'px'; });
+                band.innerHTML = '<div class="lane-title"></div>';
+                band.firstChild.innerText = lanes[i] || 'Unassigned';
+                lanesDiv.appendChild(band);
+                offset += size;
+            });
+            g.graph().width = laneRows ? extent + LANE_PAD : offset;
+            g.graph().height = laneRows ? offset : extent + LANE_PAD;
+
+            // Edges: elbows between ranks; edges that go back or stay on
+            // the same rank loop around the side. Parallel edges are spread
+            // apart, with their labels staggered so they do not overlap.
+            const count = {}, seen = {};
+            g.edges().forEach(e => { count[e.v + '>' + e.w] = (count[e.v + '>' + e.w] || 0) + 1; });
+            g.edges().forEach(e => {
+                const edge = g.edge(e), s = g.node(e.v), t = g.node(e.w);
+                const key = e.v + '>' + e.w, n = count[key];
+                const k = seen[key] = (seen[key] || 0) + 1;
+                const spread = (k - (n + 1) / 2) * 16;
+                const sEnd = s[rankAxis] + s[rankSize]/2, tEnd = t[rankAxis] - t[rankSize]/2;
+                let pts;
+                if (tEnd > sEnd) {
+                    const mid = sEnd + (tEnd - sEnd) * k / (n + 1);
+                    const sc = s[crossAxis] + spread, tc = t[crossAxis] + spread;
+                    pts = [pt(sEnd, sc), pt(mid, sc), pt(mid, tc), pt(tEnd, tc)];
+                } else {
+                    const sSide = s[crossAxis] + s[crossSize]/2, tSide = t[crossAxis] + t[crossSize]/2;
+                    const side = Math.max(sSide, tSide) + 40 * k;
+                    pts = [pt(s[rankAxis] + spread, sSide), pt(s[rankAxis] + spread, side), pt(t[rankAxis] + spread, side), pt(t[rankAxis] + spread, tSide)];
+                }
+                edge.points = pts;
+                edge.x = (pts[1].x + pts[2].x) / 2;
+                edge.y = (pts[1].y + pts[2].y) / 2;
+            });
+        }
+
+        // openSubflow drills into the child flow of a subprocess node.
+        function openSubflow(n) {
+            const sub = n.subflow || {};
+            if (!sub.flow) {
+                showInspector({ title: n.label, type: n.type, notes: 'Subflow ' + (sub.ref || '') + ' could not be loaded.', sources: '' });
+                return;
             }
-        });
+            path.push({ title: n.label, flow: sub.flow });
+            show();
+        }
 
-        // Center View
-        const gW = g.graph().width;
-        const gH = g.graph().height;
-        let scale = 1;
-        let panX = (window.innerWidth - gW)/2;
-        let panY = (window.innerHeight - gH)/2;
-        panY = Math.max(50, panY); // Ensure top margin
+        // show draws the last flow of path and the breadcrumbs leading to it.
+        function show() {
+            current = path[path.length - 1].flow;
+            render();
+            const crumbs = document.getElementById('breadcrumbs');
+            crumbs.innerHTML = '';
+            crumbs.style.display = path.length > 1 ? 'flex' : 'none';
+            path.forEach((p, i) => {
+                const crumb = document.createElement('span');
+                crumb.className = 'crumb' + (i === path.length - 1 ? ' current' : '');
+                crumb.innerText = p.title || 'Untitled';
+                if (i < path.length - 1) crumb.onclick = () => { path.length = i + 1; show(); };
+                crumbs.appendChild(crumb);
+            });
+            laneToggle.style.display = lanes.length ? 'block' : 'none';
+            closeInspector();
+            centerView();
+        }
+
+        const laneToggle = document.getElementById('lane-toggle');
+        laneToggle.onclick = function() {
+            laneRows = !laneRows;
+            laneToggle.innerText = laneRows ? 'Lanes: rows' : 'Lanes: columns';
+            render();
+            centerView();
+        };
+
+        window.onkeydown = function(e) {
+            if (e.key === 'Escape' && path.length > 1) {
+                path.pop();
+                show();
+            }
+        };
 
+        // Center View
+        let scale = 1, panX = 0, panY = 0;
         const canvas = document.getElementById('canvas');
         
         function updateTransform() {
             canvas.style.transform = 'translate(' + panX + 'px, ' + panY + 'px) scale(' + scale + ')';
         }
-        updateTransform();
+        function centerView() {
+            panX = (window.innerWidth - g.graph().width * scale)/2;
+            panY = (window.innerHeight - g.graph().height * scale)/2;
+            panY = Math.max(50, panY); // Ensure top margin
+            updateTransform();
+        }
 
         // Pan Zoom
         let isPanning = false;
@@ -497,18 +767,74 @@ func GenerateHTML(flowchartData interface{}, filename string) error {
         const inspector = document.getElementById('inspector');
         function showInspector(ds) {
              document.getElementById('ins-title').innerText = ds.title;
-             let html = '<p><strong>Type:</strong> ' + ds.type + '</p>';
-             html += '<p><strong>Logic:</strong></p><pre>' + (ds.notes || 'No details') + '</pre>';
+             // Notes and sources come from the model and the indexed documents,
+             // so everything is escaped before it is assigned to innerHTML.
+             let html = '<p><strong>Type:</strong> ' + escapeHTML(ds.type) + '</p>';
+             html += '<p><strong>Logic:</strong></p><pre>' + escapeHTML(ds.notes || 'No details') + '</pre>';
+             if (ds.sources) {
+                 html += '<p class="ins-sources-title"><strong>Sources:</strong></p><ul class="ins-sources">';
+                 ds.sources.split('\n').forEach(s => { html += '<li>' + escapeHTML(s) + '</li>'; });
+                 html += '</ul>';
+             }
              document.getElementById('ins-body').innerHTML = html;
              inspector.classList.add('visible');
         }
+        function escapeHTML(s) {
+             return s.replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c]));
+        }
         window.closeInspector = function() {
              inspector.classList.remove('visible');
         }
 
+        show();
+
     </script>
 </body>
-</html>`, string(jsonData))
+</html>`, nodeTypeCSS(types), string(viewData), string(typesData))
 
 	return os.WriteFile(filename, []byte(htmlContent), 0644)
 }
+
+// nodeTypeCSS gives every node type the size and color it has in the
+// registry. Names and colors were checked when the types were registered.
+func nodeTypeCSS(types []flow.NodeType) string {
+	var sb strings.Builder
+	for _, t := range types {
+		fmt.Fprintf(&sb, "        .node.type-%s { width: %dpx; height: %dpx; --node-color: %s; }\n", t.Name, t.Width, t.Height, t.Color)
+	}
+	return sb.String()
+}
+
+// inlineSubflows returns a copy of fc in which every subflow that refers
+// to a file also embeds that file's flow, resolved against dir. Files that
+// cannot be loaded, or that would include themselves, stay references and
+// the viewer says so. open holds the files being inlined.
+func inlineSubflows(fc flow.Flowchart, dir string, open map[string]bool) flow.Flowchart {
+	fc.Nodes = append([]flow.Node(nil), fc.Nodes...)
+	for i, n := range fc.Nodes {
+		if n.Subflow == nil {
+			continue
+		}
+		sub := *n.Subflow
+		switch {
+		case sub.Flow != nil:
+			child := inlineSubflows(*sub.Flow, dir, open)
+			sub.Flow = &child
+		case sub.Ref != "":
+			path, err := filepath.Abs(sub.Path(dir))
+			if err != nil || open[path] {
+				continue
+			}
+			child, _, err := flow.Load(path)
+			if err != nil {
+				continue
+			}
+			open[path] = true
+			child = inlineSubflows(child, filepath.Dir(path), open)
+			delete(open, path)
+			sub.Flow = &child
+		}
+		fc.Nodes[i].Subflow = &sub
+	}
+	return fc
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,13 +14,15 @@ import (
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/openai/openai-go/v3"
-	"github.com/openai/openai-go/v3/option"
 
 	"github.com/DN-OpenSource/nodey/agents"
+	"github.com/DN-OpenSource/nodey/config"
+	"github.com/DN-OpenSource/nodey/flow"
 	"github.com/DN-OpenSource/nodey/generator"
+	"github.com/DN-OpenSource/nodey/retrieval"
 )
 
 // -- Styles --
@@ -27,6 +32,7 @@ var (
 	agentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
 	logStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D7D7D"))
 	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#F44336"))
+	retryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA000"))
 )
 
 // -- States --
@@ -35,33 +41,96 @@ type state int
 const (
 	stateInput state = iota
 	stateHistory
+	stateCache // browsing cached research
 	stateAnalyzing
 	stateAnswering
 	stateResearching
 	stateArchitecting
 	stateJudging
 	stateGenerating
+	stateReview // the draft could not be approved; the user decides
 	stateDone
 )
 
 // -- Messages --
 type analysisMsg agents.AnalystResponse
-type researchMsg string
-type architectMsg agents.Flowchart
-type judgeMsg agents.JudgeResponse
+type researchMsg struct {
+	report string
+	err    error
+}
+type cacheRefreshedMsg struct {
+	entry agents.CachedResearch
+	err   error
+}
+type architectMsg struct {
+	flow flow.Flowchart
+	ops  []agents.PatchOp // set in edit mode
+}
+type judgeMsg agents.Verdict
+type judgeErrMsg struct{ err error }
 type generationMsg struct {
 	err      error
 	filename string
 }
 type errMsg struct{ err error }
 
+// cancelledMsg replaces the result of an agent call whose run was aborted.
+type cancelledMsg struct{}
+
+// runMsg is the result of a command, or a note from one of its agents,
+// tagged with the run that started it (see model.runID).
+type runMsg struct {
+	run int
+	msg tea.Msg
+}
+
+// noteMsg is a progress note an agent sent while it was still working.
+type noteMsg struct {
+	agent string
+	text  string
+}
+
+// streamMsg carries the partial reply of a streaming agent.
+type streamMsg struct {
+	agent   string
+	partial string
+}
+
+// retryMsg reports that an agent call failed and is about to be retried.
+type retryMsg struct {
+	agent string
+	event agents.RetryEvent
+}
+
+// redactMsg reports a sensitive value that was masked before a prompt was sent.
+type redactMsg agents.Redaction
+
+// usageMsg reports the token usage of one completion.
+type usageMsg struct {
+	agent string
+	model string
+	usage agents.Usage
+}
+
 // -- Model --
 type model struct {
-	client *openai.Client
+	cfg      config.Config
+	provider agents.Provider
+	redactor *agents.Redactor // nil when redaction is off
+	notes    chan tea.Msg     // progress notes from running agents
 
 	state   state
 	spinner spinner.Model
 
+	// The in-flight run; cancel aborts every agent call made with ctx.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// runID identifies the in-flight run. Agent results are tagged with
+	// the run that asked for them, so a result that lands after the run
+	// was cancelled or has ended is dropped.
+	runID int
+
 	// Input
 	textInput textarea.Model
 	prompt    string
@@ -71,30 +140,96 @@ type model struct {
 	files        []string
 	cursor       int
 	selectedFile string
-	loadedFlow   *agents.Flowchart // The flow we are editing
+	loadedFlow   *flow.Flowchart // The flow we are editing
 
 	// Analysis
-	questions       []string
-	currentQIndex   int
-	answers         []string
-	analysisSummary string
+	analysis      agents.AnalystResponse // the Analyst's latest reply
+	rounds        []agents.AnalystRound  // answered rounds of questions
+	questions     []string
+	currentQIndex int
+	answers       []string
+	requirements  agents.Requirements
+
+	// retryStatus describes the call that is waiting to be retried, if any.
+	retryStatus string
+
+	// Token usage of the current run and of the whole session
+	run     agents.Ledger
+	session agents.Ledger
+
+	prompts *agents.Prompts // built-in prompts with the user's overrides
 
 	// Research
+	docs         *retrieval.Index     // nil unless docs_dir is set
+	cache        agents.ResearchCache // Dir is empty when the cache is off
+	researchRef  *agents.ResearchRef  // cache entry of the current research
 	researchData string
 
+	// Research cache browser
+	cached       []agents.CachedResearch
+	cacheCursor  int
+	cacheViewing bool           // showing the selected report
+	refreshing   bool           // re-running the Researcher for the selected entry
+	report       viewport.Model // live view of the streaming research report
+
 	// Architecture
-	flowchart agents.Flowchart
-	revision  int
+	flowchart  flow.Flowchart
+	draftNodes int // elements parsed so far from the streaming draft
+	draftConns int
+
+	changes []agents.PatchOp // edit mode: every op applied to loadedFlow so far
+
+	// Review: every rejected draft with the critique it received
+	revisions []agents.Revision
 
-	// Judgement
-	critique string
+	// Unresolved review, shown in stateReview
+	reviewCause  string // reviewJudgeFailed, reviewMaxRevisions or reviewInvalid
+	reviewReason string
 
 	// Output
 	finalPath string
 	err       error
 }
 
-func newModel() model {
+// newProvider picks the LLM backend from the configuration.
+// Cassettes wrap the real backend when recording and replace it entirely
+// when replaying.
+func newProvider(cfg config.Config) agents.Provider {
+	switch cfg.CassetteMode {
+	case agents.CassetteReplay:
+		return &agents.ReplayProvider{Dir: cfg.CassetteDir}
+	case agents.CassetteRecord:
+		return &agents.RecordingProvider{Inner: newAPIProvider(cfg), Dir: cfg.CassetteDir}
+	}
+	return newAPIProvider(cfg)
+}
+
+// newAPIProvider returns a Provider backed by a real HTTP endpoint.
+// A base URL points Nodey at a self-hosted, OpenAI-compatible server
+// (Ollama, LM Studio, vLLM); otherwise api.openai.com is used.
+func newAPIProvider(cfg config.Config) agents.Provider {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if cfg.BaseURL != "" {
+		p := agents.NewOpenAICompatibleProvider(cfg.BaseURL, apiKey)
+		if cfg.StructuredOutputs != nil {
+			p.StructuredOutputs = *cfg.StructuredOutputs
+		}
+		return p
+	}
+	if apiKey == "" {
+		fmt.Println(errorStyle.Render("Error: OPENAI_API_KEY environment variable not set."))
+		fmt.Println("Please run: export OPENAI_API_KEY='your-key-here'")
+		fmt.Println("Or point OPENAI_BASE_URL at an OpenAI-compatible server.")
+		os.Exit(1)
+	}
+	p := agents.NewOpenAIProvider(apiKey)
+	if cfg.StructuredOutputs != nil {
+		p.StructuredOutputs = *cfg.StructuredOutputs
+	}
+	return p
+}
+
+func newModel(cfg config.Config) model {
 	ti := textarea.New()
 	ti.Placeholder = "Describe the flow you need (e.g. 'User Login Process')...\nPress Ctrl+S to submit."
 	ti.Focus()
@@ -106,27 +241,144 @@ func newModel() model {
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
-	// Initialize OpenAI Client
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		fmt.Println(errorStyle.Render("Error: OPENAI_API_KEY environment variable not set."))
-		fmt.Println("Please run: export OPENAI_API_KEY='your-key-here'")
+	history := []string{}
+	var docs *retrieval.Index
+	if cfg.DocsDir != "" {
+		var err error
+		docs, err = retrieval.Build(cfg.DocsDir)
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: cannot index docs_dir: " + err.Error()))
+			os.Exit(1)
+		}
+		history = append(history, fmt.Sprintf("System: Indexed %d passages from %d documents in %s.", docs.Len(), docs.Files, cfg.DocsDir))
+	}
+
+	for _, t := range cfg.NodeTypes {
+		if err := flow.RegisterNodeType(t); err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	prompts, err := agents.LoadPrompts(cfg.PromptDirs()...)
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: cannot load prompts: " + err.Error()))
+		os.Exit(1)
+	}
+	for _, path := range prompts.Overrides {
+		history = append(history, "System: Using prompt "+path)
+	}
+
+	// Mask secrets and personal data before any prompt leaves the machine.
+	notes := make(chan tea.Msg, 16)
+	provider := newProvider(cfg)
+	redactor, err := cfg.Redact.Redactor()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
 		os.Exit(1)
 	}
-	client := openai.NewClient(option.WithAPIKey(apiKey))
+	if redactor != nil {
+		redactor.OnRedact = func(r agents.Redaction) {
+			notes <- redactMsg(r)
+		}
+		provider = &agents.RedactingProvider{Inner: provider, Redactor: redactor}
+	}
+
+	var cache agents.ResearchCache
+	if cfg.ResearchCacheDir != "off" {
+		cache.Dir = cfg.ResearchCacheDir
+	}
 
 	return model{
-		client:    &client,
+		cfg:       cfg,
+		provider:  provider,
+		redactor:  redactor,
+		prompts:   prompts,
+		docs:      docs,
+		cache:     cache,
+		notes:     notes,
 		state:     stateInput,
 		spinner:   s,
 		textInput: ti,
-		history:   []string{},
-		revision:  0,
+		report:    viewport.New(56, 12),
+		history:   history,
+	}
+}
+
+// agent binds the session's provider to the named agent's settings.
+func (m model) agent(name string) agents.Agent {
+	notes, run := m.notes, m.runID
+	a := agents.Agent{
+		Name:       name,
+		Provider:   m.provider,
+		Settings:   *m.cfg.Agent(name),
+		Prompts:    m.prompts,
+		Timeout:    m.cfg.Timeout.Duration,
+		MaxRepairs: m.cfg.MaxRepairs,
+		Notify: func(text string) {
+			notes <- runMsg{run: run, msg: noteMsg{agent: name, text: text}}
+		},
+		OnUsage: func(model string, u agents.Usage) {
+			notes <- runMsg{run: run, msg: usageMsg{agent: name, model: model, usage: u}}
+		},
+		Retry: m.cfg.Retry.Policy(),
+		OnRetry: func(e agents.RetryEvent) {
+			notes <- runMsg{run: run, msg: retryMsg{agent: name, event: e}}
+		},
+	}
+	if name == agents.Researcher || name == agents.Architect {
+		a.Progress = func(partial string) {
+			// Drop intermediate updates rather than stall the stream;
+			// the final result arrives with the agent's own message.
+			select {
+			case notes <- runMsg{run: run, msg: streamMsg{agent: name, partial: partial}}:
+			default:
+			}
+		}
 	}
+	return a
+}
+
+// startRun resets per-run state and opens a fresh cancellable context.
+func (m *model) startRun() {
+	m.stopRun()
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.err = nil
+	m.questions, m.answers, m.currentQIndex = nil, nil, 0
+	m.rounds, m.requirements = nil, agents.Requirements{}
+	m.researchRef = nil
+	m.run = agents.Ledger{}
+	m.revisions = nil
+	m.changes = nil
+	m.report.SetContent("")
+	m.draftNodes, m.draftConns = 0, 0
+}
+
+// stopRun aborts the in-flight run, if any.
+func (m *model) stopRun() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.runID++
+	m.retryStatus = ""
+}
+
+// forRun tags the result of cmd with the current run.
+func (m model) forRun(cmd tea.Cmd) tea.Cmd {
+	run := m.runID
+	return func() tea.Msg {
+		return runMsg{run: run, msg: cmd()}
+	}
+}
+
+// busy reports whether a run is in progress.
+func (m model) busy() bool {
+	return m.state >= stateAnalyzing && m.state <= stateGenerating
 }
 
 func (m model) Init() tea.Cmd {
-	return textarea.Blink
+	return tea.Batch(textarea.Blink, waitForNote(m.notes))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -146,6 +398,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// Esc aborts the in-flight run and gives the prompt back for editing
+		if m.busy() && msg.Type == tea.KeyEsc {
+			m.stopRun()
+			m.history = append(m.history, "System: Cancelled.")
+			m.state = stateInput
+			m.textInput.SetValue(m.prompt)
+			m.textInput.Focus()
+			return m, textarea.Blink
+		}
+
 		// Input Handling
 		if m.state == stateInput {
 			switch msg.String() {
@@ -156,8 +418,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.textInput.Reset()
 				m.history = append(m.history, "User: "+m.prompt)
+				m.startRun()
 				m.state = stateAnalyzing
-				return m, tea.Batch(m.spinner.Tick, analyzeCmd(m.client, m.prompt, m.history))
+				return m, tea.Batch(m.spinner.Tick, m.forRun(analyzeCmd(m.ctx, m.agent(agents.Analyst), m.redactor, m.prompt, nil)))
+
+			case "ctrl+k":
+				if m.cache.Dir == "" {
+					m.history = append(m.history, "System: The research cache is off.")
+					return m, nil
+				}
+				cached, err := m.cache.List()
+				if err != nil {
+					m.history = append(m.history, "System: Cannot read the research cache: "+err.Error())
+					return m, nil
+				}
+				if len(cached) == 0 {
+					m.history = append(m.history, "System: No cached research yet.")
+					return m, nil
+				}
+				m.cached, m.cacheCursor, m.cacheViewing = cached, 0, false
+				m.state = stateCache
+				return m, nil
 
 			// Press Ctrl+L or some key to load history
 			case "ctrl+l":
@@ -188,20 +469,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			case tea.KeyEnter:
 				m.selectedFile = m.files[m.cursor]
-				// Load it
-				data, err := os.ReadFile(m.selectedFile)
-				if err == nil {
-					var flow agents.Flowchart
-					if err := json.Unmarshal(data, &flow); err == nil {
-						m.loadedFlow = &flow
-						m.history = append(m.history, fmt.Sprintf("System: Loaded %s. Enter changes below:", m.selectedFile))
-						m.state = stateInput
-						m.textInput.Placeholder = "What changes do you want to make?\nPress Ctrl+S to submit."
-						return m, nil
-					}
-				}
+				// Load it, upgrading files saved by older versions
+				fc, from, err := flow.Load(m.selectedFile)
 				m.state = stateInput
-				m.history = append(m.history, "System: Failed to load file.")
+				if err != nil {
+					m.history = append(m.history, "System: Failed to load file: "+err.Error())
+					return m, nil
+				}
+				m.loadedFlow = &fc
+				if from < flow.SchemaVersion {
+					m.history = append(m.history, fmt.Sprintf("System: Upgraded %s from schema version %d to %d.", m.selectedFile, from, flow.SchemaVersion))
+				}
+				m.history = append(m.history, fmt.Sprintf("System: Loaded %s. Enter changes below:", m.selectedFile))
+				m.textInput.Placeholder = "What changes do you want to make?\nPress Ctrl+S to submit."
 				return m, nil
 
 			case tea.KeyEsc:
@@ -221,6 +501,51 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// Cached research: view, refresh or discard
+		if m.state == stateCache {
+			return m.updateCache(msg)
+		}
+
+		// Unresolved review: accept, retry or edit the requirement
+		if m.state == stateReview {
+			switch msg.String() {
+			case "a":
+				if !m.canAccept() {
+					return m, nil
+				}
+				status := agents.ReviewForceApproved
+				if m.reviewCause == reviewJudgeFailed {
+					status = agents.ReviewUnreviewed
+				}
+				m.history = append(m.history, "User: Accepted the draft without approval.")
+				m.state = stateGenerating
+				return m, tea.Batch(m.spinner.Tick, m.generate(status))
+			case "r":
+				m.history = append(m.history, "User: Retrying.")
+				if m.reviewCause == reviewJudgeFailed {
+					m.state = stateJudging
+					return m, tea.Batch(m.spinner.Tick, m.judge())
+				}
+				// One more round on top of the chain
+				m.state = stateArchitecting
+				return m, tea.Batch(m.spinner.Tick, m.architect())
+			case "e", "esc":
+				m.stopRun()
+				m.history = append(m.history, "User: Editing the requirement.")
+				m.state = stateInput
+				m.textInput.SetValue(m.prompt)
+				m.textInput.Focus()
+				return m, textarea.Blink
+			}
+			return m, nil
+		}
+
+		// Scroll the live research report
+		if m.state == stateResearching {
+			m.report, cmd = m.report.Update(msg)
+			return m, cmd
+		}
+
 		// Answering Questions Handling
 		if m.state == stateAnswering {
 			switch msg.String() {
@@ -235,9 +560,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				m.currentQIndex++
 				if m.currentQIndex >= len(m.questions) {
-					// All answered
-					m.state = stateResearching
-					return m, tea.Batch(m.spinner.Tick, researchCmd(m.client, m.prompt+" "+strings.Join(m.answers, " "), m.history))
+					// All answered: back to the Analyst with the whole conversation
+					m.rounds = append(m.rounds, agents.AnalystRound{Response: m.analysis, Answers: m.answers})
+					m.questions, m.answers, m.currentQIndex = nil, nil, 0
+					m.state = stateAnalyzing
+					return m, tea.Batch(m.spinner.Tick, m.forRun(analyzeCmd(m.ctx, m.agent(agents.Analyst), m.redactor, m.prompt, m.rounds)))
 				}
 				// Next question
 				m.textInput.Placeholder = "Your answer..."
@@ -248,7 +575,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case spinner.TickMsg:
-		if m.state != stateInput && m.state != stateAnswering && m.state != stateDone {
+		if m.state != stateInput && m.state != stateAnswering && m.state != stateReview && m.state != stateDone {
 			var cmd tea.Cmd
 			m.spinner, cmd = m.spinner.Update(msg)
 			return m, cmd
@@ -256,13 +583,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case analysisMsg:
 		// Result from Analyst
+		m.analysis = agents.AnalystResponse(msg)
 		if msg.Status == "valid" {
-			m.analysisSummary = msg.Summary
-			m.history = append(m.history, "Analyst: Request is valid. "+msg.Summary)
-			m.state = stateResearching
-			return m, researchCmd(m.client, m.prompt, m.history) // Start research immediately
+			m.requirements = msg.Requirements
+			m.history = append(m.history, fmt.Sprintf("Analyst: Request is valid. %s\n  %d actors, %d triggers, %d steps, %d error cases, %d constraints",
+				msg.Requirements.Summary, len(msg.Requirements.Actors), len(msg.Requirements.Triggers),
+				len(msg.Requirements.HappyPath), len(msg.Requirements.ErrorCases), len(msg.Requirements.Constraints)))
+			return m.research() // Start research immediately
 		} else if msg.Status == "needs_info" {
-			m.history = append(m.history, fmt.Sprintf("Analyst: Need info - %s", msg.Reason))
+			m.history = append(m.history, fmt.Sprintf("Analyst: Need info (round %d) - %s", len(m.rounds)+1, msg.Reason))
 			m.questions = msg.Questions
 			m.currentQIndex = 0
 			m.state = stateAnswering
@@ -270,6 +599,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.textInput.Focus()
 			return m, textarea.Blink
 		} else {
+			m.stopRun()
 			m.state = stateInput // Go back to input
 			m.err = fmt.Errorf("Analyst rejected: %s", msg.Reason)
 			m.history = append(m.history, "Analyst: Rejected - "+msg.Reason)
@@ -277,40 +607,84 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case researchMsg:
-		m.researchData = string(msg)
-		m.history = append(m.history, "Researcher: Found relevant patterns and data.")
+		if msg.err != nil {
+			m.researchData = "Research failed but continuing..."
+			m.history = append(m.history, fmt.Sprintf("Researcher: Research failed (%v), continuing without it.", msg.err))
+		} else {
+			m.researchData = m.redactor.Restore(msg.report)
+			m.history = append(m.history, "Researcher: Found relevant patterns and data.")
+			if m.cache.Dir != "" {
+				entry, err := m.cache.Put(agents.CachedResearch{Topic: m.prompt, Model: m.researcherModel(), Requirements: m.requirements, Report: m.researchData})
+				if err != nil {
+					m.history = append(m.history, "System: Could not cache the research: "+err.Error())
+				} else {
+					m.researchRef = entry.Ref()
+				}
+			}
+		}
 		m.state = stateArchitecting
 		// Pass loadedFlow if it exists
-		return m, architectCmd(m.client, m.prompt+" "+m.analysisSummary, m.researchData, m.loadedFlow)
+		return m, m.architect()
 
 	case architectMsg:
-		m.flowchart = agents.Flowchart(msg)
-		m.history = append(m.history, fmt.Sprintf("Architect: Drafted flow with %d nodes.", len(m.flowchart.Nodes)))
+		m.flowchart = msg.flow
+		m.draftNodes, m.draftConns = 0, 0
+		if msg.ops != nil {
+			m.changes = append(m.changes, msg.ops...)
+			m.history = append(m.history, fmt.Sprintf("Architect: Applied %d changes.\n%s", len(msg.ops), agents.FormatOps(msg.ops)))
+		} else {
+			m.history = append(m.history, fmt.Sprintf("Architect: Drafted flow with %d nodes.", len(m.flowchart.Nodes)))
+		}
+
+		// Structural problems go straight back to the Architect; the Judges
+		// only see drafts that pass the validator.
+		if issues := agents.Validate(m.flowchart); len(issues) > 0 {
+			list := agents.FormatIssues(issues)
+			m.history = append(m.history, fmt.Sprintf("Validator: %d structural issues.\n%s", len(issues), list))
+			return m.revise("validator", "The validator found structural issues:\n"+list, "")
+		}
+		m.history = append(m.history, "Validator: Structure is clean.")
 		m.state = stateJudging
-		return m, judgeCmd(m.client, m.flowchart, m.prompt)
+		return m, m.judge()
 
 	case judgeMsg:
-		if msg.Approved {
-			m.history = append(m.history, "Judges: Unanimous Approval.")
+		verdict := agents.Verdict(msg)
+		approvals := 0
+		for _, v := range verdict.Votes {
+			switch {
+			case v.Approved:
+				approvals++
+				m.history = append(m.history, fmt.Sprintf("Judge %s: Approved.", v.Juror.Name))
+			default:
+				entry := fmt.Sprintf("Judge %s: Rejected - %s", v.Juror.Name, v.Critique)
+				if v.Dissent != "" {
+					entry += "\nDissent: " + v.Dissent
+				}
+				m.history = append(m.history, entry)
+			}
+		}
+		tally := fmt.Sprintf("%s, %d/%d in favour", verdict.Policy, approvals, len(verdict.Votes))
+
+		if verdict.Approved {
+			m.history = append(m.history, fmt.Sprintf("Judges: Approved (%s).", tally))
 			m.state = stateGenerating
-			return m, generateCmd(m.flowchart)
+			return m, m.generate(agents.ReviewApproved)
 		}
 		// Not approved
-		m.revision++
-		if m.revision > 3 {
-			// Fail safe
-			m.history = append(m.history, "Judges: Forced approval after max revisions.")
+		m.history = append(m.history, fmt.Sprintf("Judges: Rejected (%s). Sending back to Architect.", tally))
+		return m.revise("judges", verdict.Critique(), verdict.Dissent())
+
+	case judgeErrMsg:
+		reason := "The judges could not review the draft: " + msg.err.Error()
+		if m.cfg.ReviewPolicy == config.FailOpen {
+			m.history = append(m.history, "Judges: Review failed, failing open - "+msg.err.Error())
 			m.state = stateGenerating
-			return m, generateCmd(m.flowchart)
+			return m, m.generate(agents.ReviewUnreviewed)
 		}
-		m.critique = msg.Critique + " " + msg.Dissent
-		m.history = append(m.history, fmt.Sprintf("Judges: Critique - %s. Sending back to Architect.", msg.Critique))
-		m.state = stateArchitecting
-		// Re-architect with critique
-		reqs := fmt.Sprintf("%s. Feedback: %s", m.prompt, m.critique)
-		return m, architectCmd(m.client, reqs, m.researchData, m.loadedFlow)
+		return m.askReview(reviewJudgeFailed, reason)
 
 	case generationMsg:
+		m.stopRun()
 		if msg.err != nil {
 			m.err = msg.err
 			m.history = append(m.history, "Generator: Failed - "+msg.err.Error())
@@ -318,10 +692,97 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.finalPath = msg.filename
 			m.history = append(m.history, "Generator: Success! Saved to "+m.finalPath)
+			if m.run.Total.Calls > 0 {
+				m.history = append(m.history, fmt.Sprintf("System: This run used %s.\n%s", m.run.Total, m.run.Breakdown()))
+			}
 			m.state = stateDone
 		}
 
+	case cacheRefreshedMsg:
+		m.stopRun()
+		m.refreshing = false
+		if msg.err != nil {
+			m.history = append(m.history, "Researcher: Refresh failed: "+msg.err.Error())
+			return m, nil
+		}
+		msg.entry.Report = m.redactor.Restore(msg.entry.Report)
+		entry, err := m.cache.Put(msg.entry)
+		if err != nil {
+			m.history = append(m.history, "System: Could not cache the research: "+err.Error())
+			return m, nil
+		}
+		for i := range m.cached {
+			if m.cached[i].Key == entry.Key {
+				m.cached[i] = entry
+			}
+		}
+		m.report.SetContent(lipgloss.NewStyle().Width(m.report.Width).Render(entry.Report))
+		m.history = append(m.history, fmt.Sprintf("Researcher: Refreshed the cached research for %q.", entry.Topic))
+		return m, nil
+
+	case cancelledMsg:
+		// The run was aborted with Esc; its result is no longer wanted
+		return m, nil
+
+	case runMsg:
+		if msg.run != m.runID {
+			// The run was cancelled or has ended since the command started
+			switch note := msg.msg.(type) {
+			case usageMsg:
+				// The tokens were paid for, so they count towards the
+				// session, but not towards the ledger or budget of a run
+				// they do not belong to.
+				m.session.Add(note.agent, note.model, note.usage, m.cfg.Prices)
+				return m, waitForNote(m.notes)
+			case noteMsg, streamMsg, retryMsg:
+				return m, waitForNote(m.notes)
+			}
+			return m, nil
+		}
+		return m.Update(msg.msg)
+
+	case streamMsg:
+		switch {
+		case msg.agent == agents.Researcher && (m.state == stateResearching || m.refreshing):
+			follow := m.report.AtBottom()
+			m.report.SetContent(lipgloss.NewStyle().Width(m.report.Width).Render(msg.partial))
+			if follow {
+				m.report.GotoBottom()
+			}
+		case msg.agent == agents.Architect && m.state == stateArchitecting:
+			m.draftNodes, m.draftConns = agents.CountElements(msg.partial)
+		}
+		return m, waitForNote(m.notes)
+
+	case retryMsg:
+		m.retryStatus = fmt.Sprintf("%s: %s", agentTitle(msg.agent), msg.event)
+		return m, waitForNote(m.notes)
+
+	case usageMsg:
+		m.retryStatus = ""
+		m.run.Add(msg.agent, msg.model, msg.usage, m.cfg.Prices)
+		m.session.Add(msg.agent, msg.model, msg.usage, m.cfg.Prices)
+		if reason, over := m.cfg.Budget.Exceeded(m.run.Total); over && (m.busy() || m.refreshing) {
+			m.stopRun()
+			m.refreshing = false
+			m.err = fmt.Errorf("budget exceeded: %s", reason)
+			m.history = append(m.history, fmt.Sprintf("System: Budget exceeded (%s). The run was stopped.\n%s", reason, m.run.Breakdown()))
+			m.state = stateInput
+			m.textInput.SetValue(m.prompt)
+			m.textInput.Focus()
+		}
+		return m, waitForNote(m.notes)
+
+	case redactMsg:
+		m.history = append(m.history, fmt.Sprintf("System: Redacted %s (%s) before sending.", msg.Placeholder, msg.Detector))
+		return m, waitForNote(m.notes)
+
+	case noteMsg:
+		m.history = append(m.history, fmt.Sprintf("%s: %s", agentTitle(msg.agent), msg.text))
+		return m, waitForNote(m.notes)
+
 	case errMsg:
+		m.stopRun()
 		m.err = msg.err
 		m.history = append(m.history, "Error: "+msg.err.Error())
 		m.state = stateInput
@@ -330,8 +791,215 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// revise records the current draft and its critique in the revision chain
+// and sends it back to the Architect. Once max_revisions is exhausted a
+// draft the judges rejected is generated as is under fail-open; anything
+// else waits for the user.
+func (m model) revise(source, critique, dissent string) (tea.Model, tea.Cmd) {
+	m.revisions = append(m.revisions, agents.Revision{
+		Round:    len(m.revisions) + 1,
+		Source:   source,
+		Draft:    m.flowchart,
+		Critique: critique,
+		Dissent:  dissent,
+	})
+	if len(m.revisions) > m.cfg.MaxRevisions {
+		// A draft the validator rejects is broken, not merely disputed
+		// (the viewer cannot draw dangling connections), so it is never
+		// saved, whatever the review policy.
+		if source == "validator" {
+			return m.askReview(reviewInvalid, critique)
+		}
+		if m.cfg.ReviewPolicy == config.FailOpen {
+			// Fail safe
+			m.history = append(m.history, "System: Forced approval after max revisions.")
+			m.state = stateGenerating
+			return m, m.generate(agents.ReviewForceApproved)
+		}
+		return m.askReview(reviewMaxRevisions, critique)
+	}
+	m.state = stateArchitecting
+	return m, m.architect()
+}
+
+// Why a run ended up in stateReview.
+const (
+	reviewJudgeFailed  = "judge_failed"
+	reviewMaxRevisions = "max_revisions"
+	reviewInvalid      = "invalid" // max_revisions ran out on structural issues
+)
+
+// canAccept reports whether the user may accept the draft under review.
+// Drafts with structural issues are never accepted.
+func (m model) canAccept() bool {
+	return m.cfg.ReviewPolicy != config.FailClosed && m.reviewCause != reviewInvalid
+}
+
+// askReview parks the run in stateReview so the user can decide what
+// happens to a draft that was not approved.
+func (m model) askReview(cause, reason string) (tea.Model, tea.Cmd) {
+	m.reviewCause, m.reviewReason = cause, reason
+	m.history = append(m.history, "System: The draft was not approved. Waiting for your decision.")
+	m.state = stateReview
+	return m, nil
+}
+
+// architect asks the Architect for a new draft, or for patch operations
+// when a loaded flow is being edited.
+func (m model) architect() tea.Cmd {
+	return m.forRun(architectCmd(m.ctx, m.agent(agents.Architect), m.cfg.ArchitectMode, m.requirementsDoc(), m.researchData, m.loadedFlow, m.revisions))
+}
+
+// metadata describes how the current flow was produced.
+func (m model) metadata(review string) agents.Metadata {
+	reqs := m.requirements
+	// Copy the ledger: late usage reports must not race with saving it.
+	usage := agents.Ledger{Agents: map[string]agents.AgentUsage{}, Total: m.run.Total}
+	for name, u := range m.run.Agents {
+		usage.Agents[name] = u
+	}
+	return agents.Metadata{
+		Usage:        &usage,
+		Agents:       m.cfg.AgentSettings(),
+		Prompts:      m.prompts.Info(),
+		Requirements: &reqs,
+		Research:     m.researchRef,
+		Review:       review,
+		Changes:      m.changes,
+	}
+}
+
+// judge sends the current draft to the judge panel.
+func (m model) judge() tea.Cmd {
+	return m.forRun(judgeCmd(m.ctx, m.agent(agents.Judge), m.cfg.Judges, m.cfg.JudgePolicy, m.flowchart, m.requirementsDoc()))
+}
+
+// generate saves the current flow with the given review status.
+func (m model) generate(review string) tea.Cmd {
+	return m.forRun(generateCmd(m.ctx, m.redactor, m.flowchart, m.revisions, m.metadata(review)))
+}
+
+// research reuses cached research for the loaded flow or the topic if
+// there is any, and starts the Researcher otherwise.
+func (m model) research() (tea.Model, tea.Cmd) {
+	m.state = stateResearching
+	if m.cache.Dir != "" {
+		keys := []string{agents.ResearchKey(m.prompt, m.researcherModel())}
+		if m.loadedFlow != nil {
+			if meta, _ := agents.MetadataOf(*m.loadedFlow); meta != nil && meta.Research != nil {
+				keys = append([]string{meta.Research.Key}, keys...)
+			}
+		}
+		for _, key := range keys {
+			entry, err := m.cache.Get(key)
+			if err != nil {
+				continue
+			}
+			m.researchData, m.researchRef = entry.Report, entry.Ref()
+			m.history = append(m.history, fmt.Sprintf("Researcher: Reusing the research on %q from %s (Ctrl+K to view, refresh or discard).", entry.Topic, entry.Created.Format("Jan 2 15:04")))
+			m.state = stateArchitecting
+			return m, m.architect()
+		}
+	}
+
+	passages := m.retrieve(m.prompt, m.requirements)
+	if m.docs != nil {
+		m.history = append(m.history, fmt.Sprintf("Researcher: Retrieved %d passages from %s.", len(passages), m.cfg.DocsDir))
+	}
+	return m, m.forRun(researchCmd(m.ctx, m.agent(agents.Researcher), m.prompt, m.requirements, passages))
+}
+
+// researcherModel is the model research is cached under.
+func (m model) researcherModel() string {
+	if m.cfg.Researcher.Model == "" {
+		return agents.DefaultModel
+	}
+	return m.cfg.Researcher.Model
+}
+
+// updateCache handles keys in the research cache browser.
+func (m model) updateCache(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.refreshing {
+		if msg.Type == tea.KeyEsc {
+			m.stopRun()
+			m.refreshing = false
+			m.history = append(m.history, "System: Refresh cancelled.")
+		}
+		return m, nil
+	}
+	entry := m.cached[m.cacheCursor]
+
+	if m.cacheViewing {
+		switch msg.String() {
+		case "esc", "enter", "v":
+			m.cacheViewing = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.report, cmd = m.report.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "up":
+		if m.cacheCursor > 0 {
+			m.cacheCursor--
+		}
+	case "down":
+		if m.cacheCursor < len(m.cached)-1 {
+			m.cacheCursor++
+		}
+	case "enter", "v":
+		m.cacheViewing = true
+		m.report.SetContent(lipgloss.NewStyle().Width(m.report.Width).Render(entry.Report))
+		m.report.GotoTop()
+	case "r":
+		m.startRun()
+		m.refreshing, m.cacheViewing = true, true
+		m.report.SetContent("")
+		passages := m.retrieve(entry.Topic, entry.Requirements)
+		return m, tea.Batch(m.spinner.Tick, m.forRun(refreshCmd(m.ctx, m.agent(agents.Researcher), entry, passages)))
+	case "d":
+		if err := m.cache.Delete(entry.Key); err != nil {
+			m.history = append(m.history, "System: Could not discard the research: "+err.Error())
+			return m, nil
+		}
+		m.history = append(m.history, fmt.Sprintf("System: Discarded the cached research on %q.", entry.Topic))
+		m.cached = append(m.cached[:m.cacheCursor], m.cached[m.cacheCursor+1:]...)
+		if m.cacheCursor >= len(m.cached) {
+			m.cacheCursor = len(m.cached) - 1
+		}
+		if len(m.cached) == 0 {
+			m.state = stateInput
+		}
+	case "esc":
+		m.state = stateInput
+	}
+	return m, nil
+}
+
+// researchPassages is how many document passages the Researcher is given.
+const researchPassages = 8
+
+// retrieve finds the passages of the team's documents most relevant to
+// the request, if a docs directory is indexed.
+func (m model) retrieve(topic string, r agents.Requirements) []retrieval.Passage {
+	query := strings.Join([]string{topic, r.Summary, strings.Join(r.Triggers, " "), strings.Join(r.HappyPath, " "), strings.Join(r.ErrorCases, " ")}, " ")
+	var passages []retrieval.Passage
+	for _, res := range m.docs.Search(query, researchPassages) {
+		passages = append(passages, res.Passage)
+	}
+	return passages
+}
+
+// requirementsDoc is what downstream agents are told to build: the user's
+// request and the Analyst's requirements document.
+func (m model) requirementsDoc() string {
+	return fmt.Sprintf("## Request\n%s\n\n%s", m.prompt, m.requirements)
+}
+
 func (m model) View() string {
-	header := titleStyle.Render(" Nodey ") + "\n\n"
+	header := titleStyle.Render(" Nodey ") + "\n" + m.configView() + "\n"
 
 	// History Log
 	logView := ""
@@ -352,7 +1020,7 @@ func (m model) View() string {
 		if m.loadedFlow != nil {
 			prefix = agentStyle.Render(fmt.Sprintf("[Editing %s]", m.selectedFile)) + "\n"
 		}
-		content = prefix + "Describe your desired flowchart (Ctrl+L to Load):\n" + m.textInput.View()
+		content = prefix + "Describe your desired flowchart (Ctrl+L to Load, Ctrl+K for cached research):\n" + m.textInput.View()
 		if m.err != nil {
 			content += "\n\n" + errorStyle.Render(m.err.Error())
 		}
@@ -371,8 +1039,33 @@ func (m model) View() string {
 		content += "\n" + logStyle.Render("--------------------------------------")
 		content += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true).Render("[ o ] Open in Browser") + "   " + logStyle.Render("[ Enter ] Edit Flow") + "   " + logStyle.Render("[ Esc ] Cancel")
 
+	case stateCache:
+		entry := m.cached[m.cacheCursor]
+		switch {
+		case m.refreshing:
+			content = fmt.Sprintf("%s Researcher is refreshing %q...\n\n%s", m.spinner.View(), entry.Topic, m.report.View())
+			content += "\n" + logStyle.Render("[ Esc ] Cancel")
+		case m.cacheViewing:
+			content = agentStyle.Render(entry.Topic) + "\n\n" + m.report.View()
+			content += "\n" + logStyle.Render(fmt.Sprintf("↑/↓ scroll • %3.f%% • [ Enter ] Back", m.report.ScrollPercent()*100))
+		default:
+			content = "Cached research:\n\n"
+			for i, c := range m.cached {
+				cursor := " "
+				if m.cacheCursor == i {
+					cursor = ">"
+				}
+				content += fmt.Sprintf("%s %s %s\n", cursor, c.Topic, logStyle.Render(fmt.Sprintf("(%s, %s)", c.Model, c.Created.Format("Jan 2 15:04"))))
+			}
+			content += "\n" + logStyle.Render("--------------------------------------")
+			content += "\n" + logStyle.Render("[ Enter ] View") + "   " + logStyle.Render("[ r ] Refresh") + "   " + logStyle.Render("[ d ] Discard") + "   " + logStyle.Render("[ Esc ] Back")
+		}
+
 	case stateAnalyzing:
 		content = fmt.Sprintf("%s Analyst is thinking...", m.spinner.View())
+		if len(m.rounds) > 0 {
+			content = fmt.Sprintf("%s Analyst is reviewing your answers (round %d/%d)...", m.spinner.View(), len(m.rounds), agents.MaxAnalystRounds)
+		}
 
 	case stateAnswering:
 		q := m.questions[m.currentQIndex]
@@ -381,13 +1074,19 @@ func (m model) View() string {
 
 	case stateResearching:
 		content = fmt.Sprintf("%s Researcher is gathering data...", m.spinner.View())
+		if m.report.TotalLineCount() > 0 {
+			content += "\n\n" + m.report.View() + "\n" + logStyle.Render(fmt.Sprintf("↑/↓ scroll • %3.f%%", m.report.ScrollPercent()*100))
+		}
 
 	case stateArchitecting:
 		prefix := "Architect"
-		if m.revision > 0 {
-			prefix = fmt.Sprintf("Architect (Revision %d)", m.revision)
+		if len(m.revisions) > 0 {
+			prefix = fmt.Sprintf("Architect (Revision %d/%d)", len(m.revisions), m.cfg.MaxRevisions)
 		}
 		content = fmt.Sprintf("%s %s is designing the layout...", m.spinner.View(), prefix)
+		if m.draftNodes+m.draftConns > 0 {
+			content += "\n\n" + logStyle.Render(fmt.Sprintf("%d nodes, %d connections so far", m.draftNodes, m.draftConns))
+		}
 
 	case stateJudging:
 		content = fmt.Sprintf("%s Judges are reviewing the draft...", m.spinner.View())
@@ -395,6 +1094,29 @@ func (m model) View() string {
 	case stateGenerating:
 		content = fmt.Sprintf("%s Generating HTML artifact...", m.spinner.View())
 
+	case stateReview:
+		title := "Max revisions reached without approval."
+		switch m.reviewCause {
+		case reviewJudgeFailed:
+			title = "The draft could not be reviewed."
+		case reviewInvalid:
+			title = "Max revisions reached with structural issues left."
+		}
+		accept := logStyle.Render("[ a ] Accept anyway")
+		switch {
+		case m.reviewCause == reviewInvalid:
+			accept = logStyle.Render("[ a ] Accept (disabled: the draft is broken)")
+		case m.cfg.ReviewPolicy == config.FailClosed:
+			accept = logStyle.Render("[ a ] Accept (disabled by fail-closed policy)")
+		}
+		content = fmt.Sprintf("%s\n\nUnresolved critique:\n%s\n\n%s   %s   %s",
+			errorStyle.Render(title),
+			m.reviewReason,
+			accept,
+			agentStyle.Render("[ r ] Retry"),
+			logStyle.Render("[ e ] Edit requirement"),
+		)
+
 	case stateDone:
 		cwd, _ := os.Getwd()
 		absPath := filepath.Join(cwd, m.finalPath)
@@ -407,6 +1129,10 @@ func (m model) View() string {
 		)
 	}
 
+	if m.retryStatus != "" && (m.busy() || m.refreshing) {
+		content += "\n\n" + retryStyle.Render("↻ "+m.retryStatus)
+	}
+
 	// Fancy Box for Content
 	contentBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
@@ -415,60 +1141,180 @@ func (m model) View() string {
 		Width(60).
 		Render(content)
 
-	return appStyle.Render(lipgloss.JoinVertical(lipgloss.Left, header, logView, contentBox, "\n\n"+logStyle.Render("q: quit")))
+	footer := "q: quit"
+	if m.session.Total.Calls > 0 {
+		footer = fmt.Sprintf("run %s • session %s • %s", m.run.Total, m.session.Total, footer)
+	}
+	if m.busy() {
+		footer = "esc: cancel • " + footer
+	}
+
+	return appStyle.Render(lipgloss.JoinVertical(lipgloss.Left, header, logView, contentBox, "\n\n"+logStyle.Render(footer)))
+}
+
+// configView lists the effective model settings of every agent.
+func (m model) configView() string {
+	lines := []string{}
+	for _, name := range agents.Names {
+		line := fmt.Sprintf("%-10s %s", name, m.cfg.Agent(name).String())
+		if name == agents.Architect && m.cfg.ArchitectMode == config.ArchitectTools {
+			line += " (tools)"
+		}
+		lines = append(lines, line)
+	}
+	panel := m.cfg.Judges
+	if len(panel) == 0 {
+		panel = agents.DefaultPanel
+	}
+	lines = append(lines, fmt.Sprintf("%-10s %d jurors, %s, %s", "panel", len(panel), m.cfg.JudgePolicy, m.cfg.ReviewPolicy))
+	if m.cfg.BaseURL != "" {
+		lines = append(lines, "endpoint   "+m.cfg.BaseURL)
+	}
+	if n := len(m.prompts.Overrides); n > 0 {
+		lines = append(lines, fmt.Sprintf("prompts    %s (%d overridden)", m.prompts.Hash(), n))
+	}
+	if len(m.cfg.NodeTypes) > 0 {
+		names := make([]string, len(m.cfg.NodeTypes))
+		for i, t := range m.cfg.NodeTypes {
+			names[i] = t.Name
+		}
+		lines = append(lines, fmt.Sprintf("%-10s %s (custom)", "node types", strings.Join(names, ", ")))
+	}
+	if m.cfg.CassetteMode != "" {
+		lines = append(lines, fmt.Sprintf("cassettes  %s %s", m.cfg.CassetteMode, m.cfg.CassetteDir))
+	}
+	return logStyle.Render(strings.Join(lines, "\n")) + "\n"
 }
 
 // -- Commands --
 
-func analyzeCmd(client *openai.Client, input string, history []string) tea.Cmd {
+// waitForNote delivers the next progress note from a running agent.
+func waitForNote(notes chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		res, err := agents.AnalyzeRequest(client, input, history)
+		return <-notes
+	}
+}
+
+func analyzeCmd(ctx context.Context, a agents.Agent, r *agents.Redactor, input string, rounds []agents.AnalystRound) tea.Cmd {
+	return func() tea.Msg {
+		res, err := agents.AnalyzeRequest(ctx, a, input, rounds)
+		if ctx.Err() == context.Canceled {
+			return cancelledMsg{}
+		}
 		if err != nil {
 			return analysisMsg{Status: "error", Reason: err.Error()}
 		}
+
+		// The user sees the questions and requirements, so anything the
+		// Analyst quoted from redacted input is restored first.
+		res, err = agents.Unredact(r, res)
+		if err != nil {
+			return errMsg{fmt.Errorf("failed to restore redacted values: %w", err)}
+		}
 		return analysisMsg(res)
 	}
 }
 
-func researchCmd(client *openai.Client, topic string, history []string) tea.Cmd {
+func researchCmd(ctx context.Context, a agents.Agent, topic string, reqs agents.Requirements, passages []retrieval.Passage) tea.Cmd {
 	return func() tea.Msg {
-		res, err := agents.Research(client, topic, history)
-		if err != nil {
-			return researchMsg("Research failed but continuing...")
+		res, err := agents.Research(ctx, a, topic, reqs, passages)
+		if ctx.Err() == context.Canceled {
+			return cancelledMsg{}
 		}
-		return researchMsg(res)
+		return researchMsg{report: res, err: err}
 	}
 }
 
-func architectCmd(client *openai.Client, reqs, research string, currentFlow *agents.Flowchart) tea.Cmd {
+// refreshCmd re-runs the Researcher for a cached entry.
+func refreshCmd(ctx context.Context, a agents.Agent, entry agents.CachedResearch, passages []retrieval.Passage) tea.Cmd {
 	return func() tea.Msg {
-		res, err := agents.GenerateFlowchart(client, reqs, research, currentFlow)
+		a.Settings.Model = entry.Model
+		res, err := agents.Research(ctx, a, entry.Topic, entry.Requirements, passages)
+		if ctx.Err() == context.Canceled {
+			return cancelledMsg{}
+		}
+		entry.Report, entry.Created = res, time.Time{}
+		return cacheRefreshedMsg{entry: entry, err: err}
+	}
+}
+
+func architectCmd(ctx context.Context, a agents.Agent, mode, reqs, research string, currentFlow *flow.Flowchart, revisions []agents.Revision) tea.Cmd {
+	return func() tea.Msg {
+		if mode == config.ArchitectTools {
+			res, ops, err := agents.BuildFlowchart(ctx, a, reqs, research, currentFlow, revisions)
+			if ctx.Err() == context.Canceled {
+				return cancelledMsg{}
+			}
+			if err != nil {
+				return errMsg{err}
+			}
+			if currentFlow == nil {
+				// A new flow is all additions; the ops are not worth listing.
+				ops = nil
+			}
+			return architectMsg{flow: res, ops: ops}
+		}
+
+		if currentFlow != nil {
+			res, ops, err := agents.EditFlowchart(ctx, a, reqs, research, *currentFlow, revisions)
+			if ctx.Err() == context.Canceled {
+				return cancelledMsg{}
+			}
+			if err != nil {
+				return errMsg{err}
+			}
+			return architectMsg{flow: res, ops: ops}
+		}
+
+		res, err := agents.GenerateFlowchart(ctx, a, reqs, research, nil, revisions)
+		if ctx.Err() == context.Canceled {
+			return cancelledMsg{}
+		}
 		if err != nil {
 			return errMsg{err}
 		}
-		return architectMsg(res)
+		return architectMsg{flow: res}
 	}
 }
 
-func judgeCmd(client *openai.Client, fc agents.Flowchart, reqs string) tea.Cmd {
+func judgeCmd(ctx context.Context, a agents.Agent, panel []agents.Juror, policy string, fc flow.Flowchart, reqs string) tea.Cmd {
 	return func() tea.Msg {
 		// Serialize flow to json for the judge
 		jsonBytes, err := json.Marshal(fc)
 		if err != nil {
-			// Fallback string representation
-			return judgeMsg{Approved: true}
+			return judgeErrMsg{err}
 		}
 
-		res, err := agents.Judgement(client, string(jsonBytes), reqs)
+		res, err := agents.JudgePanel(ctx, a, panel, policy, string(jsonBytes), reqs)
+		if ctx.Err() == context.Canceled {
+			return cancelledMsg{}
+		}
 		if err != nil {
-			return judgeMsg{Approved: true} // Fail open on API error so user gets result
+			return judgeErrMsg{err} // The review policy decides whether to fail open
 		}
 		return judgeMsg(res)
 	}
 }
 
-func generateCmd(fc agents.Flowchart) tea.Cmd {
+func generateCmd(ctx context.Context, r *agents.Redactor, fc flow.Flowchart, revisions []agents.Revision, meta agents.Metadata) tea.Cmd {
 	return func() tea.Msg {
+		if ctx.Err() != nil {
+			return cancelledMsg{}
+		}
+
+		// The agents only ever saw placeholders; the saved files get the real values.
+		var err error
+		fc, err = agents.Unredact(r, fc)
+		if err == nil {
+			meta, err = agents.Unredact(r, meta)
+		}
+		if err == nil {
+			revisions, err = agents.Unredact(r, revisions)
+		}
+		if err != nil {
+			return generationMsg{err: fmt.Errorf("failed to restore redacted values: %w", err)}
+		}
+
 		// Create a sanitized filename from the title
 		title := fc.Overview.Title
 		if title == "" {
@@ -487,7 +1333,34 @@ func generateCmd(fc agents.Flowchart) tea.Cmd {
 		timestamp := time.Now().Format("20060102_150405")
 		filename := fmt.Sprintf("%s_%s_flow.html", safeTitle, timestamp)
 
-		err := generator.GenerateHTML(fc, filename)
+		// The run may have been cancelled while the values were restored
+		if ctx.Err() != nil {
+			return cancelledMsg{}
+		}
+
+		// Keep the revision chain next to the flow so convergence can be reviewed
+		if len(revisions) > 0 {
+			revFile := fmt.Sprintf("%s_%s_revisions.json", safeTitle, timestamp)
+			data, err := json.MarshalIndent(revisions, "", "  ")
+			if err == nil {
+				err = os.WriteFile(revFile, data, 0644)
+			}
+			if err != nil {
+				return generationMsg{err: fmt.Errorf("failed to save revisions: %w", err)}
+			}
+			meta.Revisions = len(revisions)
+			meta.RevisionLog = revFile
+		}
+
+		// Record the effective configuration next to the flow
+		if err := meta.Attach(&fc); err != nil {
+			return generationMsg{err: err}
+		}
+
+		if ctx.Err() != nil {
+			return cancelledMsg{}
+		}
+		err = generator.GenerateHTML(fc, filename)
 		// Return the filename in the msg so we can show it
 		if err == nil {
 			return generationMsg{err: nil, filename: filename}
@@ -497,13 +1370,30 @@ func generateCmd(fc agents.Flowchart) tea.Cmd {
 }
 
 func main() {
-	p := tea.NewProgram(newModel(), tea.WithAltScreen())
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		os.Exit(2)
+	}
+
+	p := tea.NewProgram(newModel(cfg), tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
 }
 
+// agentTitle turns an agent name into a display name ("architect" -> "Architect").
+func agentTitle(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
 // Helper to find json files
 func getJSONFiles() ([]string, error) {
 	files, err := os.ReadDir(".")
@@ -512,11 +1402,8 @@ func getJSONFiles() ([]string, error) {
 	}
 	var jsons []string
 	for _, f := range files {
-		// Look for files ending in _flow.json to keep it clean
-		if !f.IsDir() && strings.HasSuffix(f.Name(), "_flow.json") {
-			jsons = append(jsons, f.Name())
-		} else if strings.EqualFold(f.Name(), "flowchart.json") {
-			// Also include the legacy/default one
+		// Saved flows end in _flow.json; the legacy flowchart.json is migrated on load
+		if !f.IsDir() && flow.IsFlowFile(f.Name()) {
 			jsons = append(jsons, f.Name())
 		}
 	}
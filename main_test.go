@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/DN-OpenSource/nodey/agents"
+	"github.com/DN-OpenSource/nodey/config"
+	"github.com/DN-OpenSource/nodey/flow"
+)
+
+var update = flag.Bool("update", false, "record the cassettes and golden files in testdata again")
+
+// newTestModel returns a model that talks to provider and saves flows in a
+// fresh working directory. Redaction, retries and the research cache are
+// off so a run is the same every time.
+func newTestModel(t *testing.T, provider agents.Provider, adjust func(*config.Config)) model {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no user prompt overrides
+
+	cfg := config.Default()
+	cfg.CassetteMode = agents.CassetteReplay // never reach for an API key
+	cfg.ResearchCacheDir = "off"
+	cfg.Redact = config.Redact{}
+	cfg.Retry.MaxAttempts = 1
+	if adjust != nil {
+		adjust(&cfg)
+	}
+	m := newModel(cfg)
+	m.provider = provider
+	return m
+}
+
+// submit types text into the focused input and presses Ctrl+S.
+func submit(m model, text string) model {
+	m.textInput.SetValue(text)
+	return drive(m, tea.KeyMsg{Type: tea.KeyCtrlS})
+}
+
+// drive feeds msg to m, then every message the resulting commands produce,
+// until the model waits for the user. Commands run one at a time and the
+// notes they send are delivered before their result, so a run is the same
+// every time.
+func drive(m model, msg tea.Msg) model {
+	queue := []tea.Msg{msg}
+	for len(queue) > 0 {
+		next, cmd := m.Update(queue[0])
+		m = next.(model)
+		msgs, notes := collect(m.notes, cmd)
+		for _, note := range notes {
+			// The command a note returns only waits for the next one.
+			next, _ = m.Update(note)
+			m = next.(model)
+		}
+		queue = append(queue[1:], msgs...)
+	}
+	return m
+}
+
+// collect runs cmd and returns the messages it produced along with the
+// notes sent on notes meanwhile, which the runtime would otherwise read.
+func collect(notes chan tea.Msg, cmd tea.Cmd) (msgs, sent []tea.Msg) {
+	done := make(chan []tea.Msg)
+	go func() { done <- run(cmd) }()
+	for {
+		select {
+		case note := <-notes:
+			sent = append(sent, note)
+		case msgs := <-done:
+			for {
+				select {
+				case note := <-notes:
+					sent = append(sent, note)
+				default:
+					return msgs, sent
+				}
+			}
+		}
+	}
+}
+
+// run executes cmd and the commands of any batch it returns. Spinner ticks
+// and cursor blinks are dropped: they only animate the view and never stop.
+func run(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var out []tea.Msg
+		for _, c := range batch {
+			out = append(out, run(c)...)
+		}
+		return out
+	}
+	if t := reflect.TypeOf(msg); t == nil || strings.HasPrefix(t.PkgPath(), "github.com/charmbracelet/bubbles/") {
+		return nil
+	}
+	return []tea.Msg{msg}
+}
+
+// Canned replies for scripted runs.
+const (
+	analystValid = `{"status": "valid", "reason": "", "questions": [], "requirements": {
+		"summary": "A user resets a forgotten password through an emailed link.",
+		"actors": ["User", "Auth service"],
+		"triggers": ["User clicks \"Forgot password\""],
+		"happy_path": ["User enters their email", "Auth service emails a single-use reset link", "User sets a new password"],
+		"error_cases": ["The link has expired"],
+		"constraints": ["Links expire after 30 minutes"]}}`
+
+	researchReport = "## Patterns\n- Reset tokens are single-use and expire.\n- The reply never reveals whether an email is registered.\n"
+
+	cleanDraft = `{"overview": {"title": "Password Reset", "summary": "Reset a forgotten password by email."},
+		"nodes": [
+			{"id": "n1", "type": "start", "title": "Forgot password", "notes": ""},
+			{"id": "n2", "type": "manual_input", "title": "Enter email", "notes": ""},
+			{"id": "n3", "type": "io", "title": "Email reset link", "notes": "Token valid for 30 minutes"},
+			{"id": "n4", "type": "decision", "title": "Link still valid?", "notes": ""},
+			{"id": "n5", "type": "action", "title": "Set new password", "notes": ""},
+			{"id": "n6", "type": "end", "title": "Done", "notes": ""},
+			{"id": "n7", "type": "end", "title": "Link expired", "notes": ""}
+		],
+		"connections": [
+			{"from": "n1", "to": "n2", "type": "out"},
+			{"from": "n2", "to": "n3", "type": "out"},
+			{"from": "n3", "to": "n4", "type": "out"},
+			{"from": "n4", "to": "n5", "type": "yes"},
+			{"from": "n4", "to": "n7", "type": "no"},
+			{"from": "n5", "to": "n6", "type": "out"}
+		]}`
+
+	// brokenDraft has a connection to a node that does not exist.
+	brokenDraft = `{"overview": {"title": "Password Reset", "summary": ""},
+		"nodes": [
+			{"id": "n1", "type": "start", "title": "Forgot password", "notes": ""},
+			{"id": "n2", "type": "end", "title": "Done", "notes": ""}
+		],
+		"connections": [
+			{"from": "n1", "to": "n2", "type": "out"},
+			{"from": "n1", "to": "n9", "type": "out"}
+		]}`
+
+	approval = `{"approved": true, "critique": "", "dissent": ""}`
+)
+
+// scriptedRun answers the calls of one clean run: the Analyst accepts the
+// request, the Architect's first draft passes the validator and all three
+// default jurors approve it.
+func scriptedRun() *agents.ScriptedProvider {
+	return agents.NewScriptedProvider(analystValid, researchReport, cleanDraft, approval, approval, approval)
+}
+
+var timestamp = regexp.MustCompile(`\d{8}_\d{6}`)
+
+// TestReplayGolden drives a whole run, from the request through analysis,
+// research, drafting and review to the saved flow, against the cassettes
+// in testdata/cassettes, and compares the log and the saved flow with
+// testdata/replay.golden. Run with -update after changing a prompt.
+func TestReplayGolden(t *testing.T) {
+	cassettes, err := filepath.Abs(filepath.Join("testdata", "cassettes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := filepath.Abs(filepath.Join("testdata", "replay.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var provider agents.Provider = &agents.ReplayProvider{Dir: cassettes}
+	if *update {
+		if err := os.RemoveAll(cassettes); err != nil {
+			t.Fatal(err)
+		}
+		provider = &agents.RecordingProvider{Inner: scriptedRun(), Dir: cassettes}
+	}
+	m := newTestModel(t, provider, nil)
+
+	m = submit(m, "Password reset by email")
+	if m.state != stateDone {
+		t.Fatalf("run ended in state %d, want stateDone:\n%s", m.state, strings.Join(m.history, "\n"))
+	}
+	saved, err := os.ReadFile(strings.TrimSuffix(m.finalPath, ".html") + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := timestamp.ReplaceAllString(strings.Join(m.history, "\n")+"\n\n"+string(saved)+"\n", "TIMESTAMP")
+
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("run differs from %s (rerun with -update if the change is intended):\n%s", golden, got)
+	}
+}
+
+// TestStaleResultsAreDropped checks that results of a run that was
+// cancelled with Esc no longer drive the model.
+func TestStaleResultsAreDropped(t *testing.T) {
+	provider := scriptedRun()
+	m := newTestModel(t, provider, nil)
+	m.textInput.SetValue("Password reset by email")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	m = next.(model)
+	stale := m.runID
+	late, _ := collect(m.notes, cmd) // the Analyst answers...
+
+	m = drive(m, tea.KeyMsg{Type: tea.KeyEsc}) // ...just after the user cancelled
+	for _, msg := range append(late,
+		runMsg{run: stale, msg: architectMsg{flow: flow.Flowchart{Nodes: []flow.Node{{ID: "n1", Type: "start"}}}}},
+		runMsg{run: stale, msg: generationMsg{filename: "late_flow.html"}},
+	) {
+		m = drive(m, msg)
+		if m.state != stateInput {
+			t.Fatalf("%T of the cancelled run moved the model to state %d", msg, m.state)
+		}
+	}
+	if n := len(provider.Requests); n != 1 {
+		t.Errorf("the cancelled run made %d calls, want only the Analyst's", n)
+	}
+	if m.finalPath != "" {
+		t.Errorf("the cancelled run was reported as saved to %s", m.finalPath)
+	}
+}
+
+// TestGenerateCancelled checks that a cancelled run writes no files.
+func TestGenerateCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fc := flow.Flowchart{Overview: flow.Overview{Title: "Cancelled"}}
+	if msg := generateCmd(ctx, nil, fc, nil, agents.Metadata{})(); msg != (cancelledMsg{}) {
+		t.Errorf("generateCmd returned %#v, want cancelledMsg", msg)
+	}
+	if files, _ := os.ReadDir("."); len(files) != 0 {
+		t.Errorf("a cancelled run wrote %d files", len(files))
+	}
+}
+
+// TestBrokenDraftIsNeverSaved checks that a draft which still fails the
+// validator once max_revisions runs out is not saved, even under the
+// fail-open policy, and cannot be accepted by hand.
+func TestBrokenDraftIsNeverSaved(t *testing.T) {
+	provider := agents.NewScriptedProvider(analystValid, researchReport, brokenDraft, brokenDraft)
+	m := newTestModel(t, provider, func(cfg *config.Config) {
+		cfg.MaxRevisions = 1
+		cfg.ReviewPolicy = config.FailOpen
+	})
+
+	m = submit(m, "Password reset by email")
+	if m.state != stateReview || m.reviewCause != reviewInvalid {
+		t.Fatalf("run ended in state %d (%s), want the review screen:\n%s", m.state, m.reviewCause, strings.Join(m.history, "\n"))
+	}
+	m = drive(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if m.state != stateReview {
+		t.Errorf("accepting a broken draft moved the model to state %d", m.state)
+	}
+	if files, _ := os.ReadDir("."); len(files) != 0 {
+		t.Errorf("a broken draft was written to %d files", len(files))
+	}
+}
+
+// TestAnalystOutputIsRestored checks that values redacted from the request
+// are put back in what the Analyst returns before the user sees it.
+func TestAnalystOutputIsRestored(t *testing.T) {
+	provider := agents.NewScriptedProvider(`{"status": "needs_info", "reason": "", "questions": ["Should [EMAIL_1] be notified?"], "requirements": {}}`)
+	m := newTestModel(t, nil, func(cfg *config.Config) {
+		cfg.Redact = config.Redact{Detectors: []string{"email"}}
+	})
+	m.provider = &agents.RedactingProvider{Inner: provider, Redactor: m.redactor}
+
+	m = submit(m, "Alert ops@example.com when the import fails")
+	if sent := provider.Requests[0].Messages[1].Content; strings.Contains(sent, "ops@example.com") {
+		t.Errorf("the email was sent to the model: %q", sent)
+	}
+	if m.state != stateAnswering || m.questions[0] != "Should ops@example.com be notified?" {
+		t.Errorf("state %d, questions %q", m.state, m.questions)
+	}
+}
+
+// TestPartialJudgeFailureIsNotApproval checks that a draft only one juror
+// approved, because the others failed, is not saved as approved.
+func TestPartialJudgeFailureIsNotApproval(t *testing.T) {
+	for _, policy := range []string{config.FailClosed, config.FailOpen} {
+		t.Run(policy, func(t *testing.T) {
+			provider := agents.NewScriptedProvider(analystValid, researchReport, cleanDraft, approval)
+			provider.PushError(errors.New("timeout"))
+			provider.PushError(errors.New("timeout"))
+			m := newTestModel(t, provider, func(cfg *config.Config) { cfg.ReviewPolicy = policy })
+
+			m = submit(m, "Password reset by email")
+			if policy == config.FailClosed {
+				if m.state != stateReview || m.reviewCause != reviewJudgeFailed {
+					t.Fatalf("run ended in state %d (%s), want the review screen:\n%s", m.state, m.reviewCause, strings.Join(m.history, "\n"))
+				}
+				if files, _ := os.ReadDir("."); len(files) != 0 {
+					t.Errorf("the draft was written to %d files", len(files))
+				}
+				return
+			}
+			saved, err := os.ReadFile(strings.TrimSuffix(m.finalPath, ".html") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(saved), `"review": "unreviewed"`) {
+				t.Errorf("saved flow is not marked unreviewed:\n%s", saved)
+			}
+		})
+	}
+}
+
+// TestStaleNotesAreDropped checks that usage and notes an agent of a
+// cancelled run reports late do not count against the next run.
+func TestStaleNotesAreDropped(t *testing.T) {
+	m := newTestModel(t, scriptedRun(), func(cfg *config.Config) {
+		cfg.Budget = agents.Budget{Tokens: 1000}
+	})
+	step := func(msg tea.Msg) tea.Cmd {
+		next, cmd := m.Update(msg)
+		m = next.(model)
+		return cmd
+	}
+	m.textInput.SetValue("Password reset by email")
+	step(tea.KeyMsg{Type: tea.KeyCtrlS})
+	stale := m.runID
+	step(tea.KeyMsg{Type: tea.KeyEsc})
+	m.textInput.SetValue("Password reset by email")
+	step(tea.KeyMsg{Type: tea.KeyCtrlS})
+	history := len(m.history)
+
+	over := usageMsg{agent: agents.Analyst, model: "gpt-5", usage: agents.Usage{PromptTokens: 5000}}
+	for _, msg := range []tea.Msg{
+		over,
+		noteMsg{agent: agents.Analyst, text: "late"},
+		retryMsg{agent: agents.Analyst, event: agents.RetryEvent{Attempt: 1, Err: errors.New("boom")}},
+	} {
+		if cmd := step(runMsg{run: stale, msg: msg}); cmd == nil {
+			t.Errorf("a stale %T stopped the model from reading notes", msg)
+		}
+	}
+	if m.state != stateAnalyzing || m.err != nil {
+		t.Fatalf("stale usage stopped the new run: state %d, %v", m.state, m.err)
+	}
+	if m.run.Total.Calls != 0 || m.session.Total.Calls != 1 {
+		t.Errorf("stale usage counted %d calls for the run and %d for the session, want 0 and 1", m.run.Total.Calls, m.session.Total.Calls)
+	}
+	if len(m.history) != history || m.retryStatus != "" {
+		t.Errorf("stale notes were shown: %q, %q", m.history[history:], m.retryStatus)
+	}
+
+	step(runMsg{run: m.runID, msg: over})
+	if m.state != stateInput || m.err == nil {
+		t.Errorf("usage of the current run over the budget left the model in state %d", m.state)
+	}
+}
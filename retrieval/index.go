@@ -0,0 +1,237 @@
+// Package retrieval indexes a directory of team documents (Markdown, text,
+// OpenAPI YAML and Go source) and finds the passages most relevant to a
+// query with BM25. Everything is kept in memory; nothing leaves the machine.
+package retrieval
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Extensions are the file types that get indexed.
+var Extensions = []string{".md", ".markdown", ".txt", ".yaml", ".yml", ".go"}
+
+// maxFileSize skips generated or vendored blobs that would drown the index.
+const maxFileSize = 1 << 20
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Passage is a chunk of one document.
+type Passage struct {
+	Path string // relative to the indexed directory, with forward slashes
+	Line int    // first line of the passage, 1-based
+	Text string
+}
+
+// Citation is the tag used to cite the passage in a report, e.g. "docs/auth.md:12".
+func (p Passage) Citation() string {
+	return fmt.Sprintf("%s:%d", p.Path, p.Line)
+}
+
+// Result is a passage with its relevance score.
+type Result struct {
+	Passage
+	Score float64
+}
+
+// Index is a BM25 index over the passages of a directory.
+type Index struct {
+	Dir      string
+	Files    int
+	passages []Passage
+	terms    []map[string]int // term frequencies per passage
+	lengths  []int
+	df       map[string]int // number of passages containing a term
+	avgLen   float64
+}
+
+// Build indexes every supported file below dir. Hidden directories,
+// vendor and node_modules are skipped.
+func Build(dir string) (*Index, error) {
+	idx := &Index{Dir: dir, df: map[string]int{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !supported(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		passages, err := chunkFile(path, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		idx.Files++
+		for _, p := range passages {
+			idx.add(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, l := range idx.lengths {
+		total += l
+	}
+	if len(idx.lengths) > 0 {
+		idx.avgLen = float64(total) / float64(len(idx.lengths))
+	}
+	return idx, nil
+}
+
+// Len is the number of indexed passages.
+func (idx *Index) Len() int {
+	return len(idx.passages)
+}
+
+func (idx *Index) add(p Passage) {
+	tf := map[string]int{}
+	n := 0
+	for _, t := range tokenize(p.Text) {
+		tf[t]++
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	for t := range tf {
+		idx.df[t]++
+	}
+	idx.passages = append(idx.passages, p)
+	idx.terms = append(idx.terms, tf)
+	idx.lengths = append(idx.lengths, n)
+}
+
+// Search returns up to k passages ranked by BM25 score. Passages that share
+// no term with the query are never returned.
+func (idx *Index) Search(query string, k int) []Result {
+	if idx == nil || len(idx.passages) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+
+	n := float64(len(idx.passages))
+	var results []Result
+	for i, tf := range idx.terms {
+		score := 0.0
+		for _, t := range terms {
+			f := float64(tf[t])
+			if f == 0 {
+				continue
+			}
+			df := float64(idx.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := f + k1*(1-b+b*float64(idx.lengths[i])/idx.avgLen)
+			score += idf * f * (k1 + 1) / norm
+		}
+		if score > 0 {
+			results = append(results, Result{Passage: idx.passages[i], Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func supported(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Chunk sizes in bytes. A passage is closed at the first natural break
+// after minChunk and forcibly at maxChunk.
+const (
+	minChunk = 600
+	maxChunk = 1500
+)
+
+// chunkFile splits a file into passages at blank lines, Markdown headings
+// and top-level Go declarations.
+func chunkFile(path, rel string) ([]Passage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var passages []Passage
+	var buf strings.Builder
+	start := 1
+	flush := func(next int) {
+		if text := strings.TrimSpace(buf.String()); text != "" {
+			passages = append(passages, Passage{Path: rel, Line: start, Text: text})
+		}
+		buf.Reset()
+		start = next
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxFileSize)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if buf.Len() == 0 && strings.TrimSpace(text) == "" {
+			continue // a passage starts, and is cited, at its first text
+		}
+		if isBoundary(text) && buf.Len() > 0 {
+			flush(line)
+		}
+		if buf.Len() == 0 {
+			start = line
+		}
+		buf.WriteString(text + "\n")
+		if (strings.TrimSpace(text) == "" && buf.Len() >= minChunk) || buf.Len() >= maxChunk {
+			flush(line + 1)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", rel, err)
+	}
+	flush(0)
+	return passages, nil
+}
+
+// isBoundary reports whether a line starts a new section of its document.
+func isBoundary(line string) bool {
+	return strings.HasPrefix(line, "#") ||
+		strings.HasPrefix(line, "func ") ||
+		strings.HasPrefix(line, "type ")
+}
@@ -0,0 +1,119 @@
+package retrieval
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files below dir, making directories as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/auth.md":      "# Login\nUsers sign in with a password. Failed logins are rate limited.\n",
+		"docs/tokens.md":    "# Tokens\nThe refresh token rotates on every use. A refresh token expires after 30 days.\n",
+		"docs/billing.md":   "# Billing\nInvoices are emailed monthly. Each invoice lists every charge.\n",
+		"api/openapi.yaml":  "paths:\n  /token/refresh:\n    post:\n      summary: Exchange the token for a new pair of credentials and audit the session\n",
+		".git/notes.md":     "refresh token refresh token",
+		"vendor/lib/doc.md": "refresh token refresh token",
+		"diagram.png":       "refresh token",
+	})
+	idx, err := Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Files != 4 || idx.Len() != 4 {
+		t.Errorf("indexed %d passages of %d files, want 4 of 4", idx.Len(), idx.Files)
+	}
+
+	results := idx.Search("How is the refresh token rotated?", 10)
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Citation())
+	}
+	// tokens.md uses both terms twice in a short passage; openapi.yaml
+	// uses each once in a longer one.
+	if strings.Join(got, " ") != "docs/tokens.md:1 api/openapi.yaml:1" {
+		t.Errorf("results are %q", got)
+	}
+	if len(results) == 2 && results[0].Score <= results[1].Score {
+		t.Errorf("scores %v are not in descending order", results)
+	}
+
+	if r := idx.Search("refresh token", 1); len(r) != 1 || r[0].Path != "docs/tokens.md" {
+		t.Errorf("k=1 gave %v", r)
+	}
+	// A term found in one passage outweighs one found in several.
+	if r := idx.Search("password token", 10); len(r) != 3 || r[0].Path != "docs/auth.md" {
+		t.Errorf("rare term ranking gave %v", r)
+	}
+	if r := idx.Search("the of and", 10); r != nil {
+		t.Errorf("a query of stopwords matched %v", r)
+	}
+	if r := idx.Search("kubernetes", 10); r != nil {
+		t.Errorf("an unrelated query matched %v", r)
+	}
+	var none *Index
+	if r := none.Search("refresh", 10); r != nil {
+		t.Errorf("a nil index matched %v", r)
+	}
+}
+
+func TestChunkFile(t *testing.T) {
+	long := strings.Repeat("word ", 140) // 700 bytes, past minChunk
+	short := strings.Repeat("step\n", 400)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []int // first line of every passage
+	}{
+		{"headings", "a.md", "# Auth\nTokens expire.\n\n## Refresh\nUse the refresh token.\n", []int{1, 4}},
+		{"short paragraphs stay together", "a.md", "One.\n\nTwo.\n\nThree.\n", []int{1}},
+		{"blank line after minChunk", "a.md", long + "\n\nTail.\n", []int{1, 3}},
+		{"go declarations", "a.go", "package x\n\nfunc A() {}\n\ntype B struct{}\n\nfunc C() {}\n", []int{1, 3, 5, 7}},
+		{"forced at maxChunk", "a.txt", short, []int{1, 301}},
+		{"leading blank lines", "a.md", "\n\n\nText.\n", []int{4}},
+		{"empty", "a.md", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			passages, err := chunkFile(path, "docs/"+tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var starts []int
+			for _, p := range passages {
+				starts = append(starts, p.Line)
+				if len(p.Text) > maxChunk {
+					t.Errorf("passage at line %d has %d bytes, over maxChunk", p.Line, len(p.Text))
+				}
+				if p.Text != strings.TrimSpace(p.Text) || p.Path != "docs/"+tt.file {
+					t.Errorf("passage %+v", p)
+				}
+			}
+			if !slices.Equal(starts, tt.want) {
+				t.Errorf("passages start at lines %v, want %v", starts, tt.want)
+			}
+		})
+	}
+}
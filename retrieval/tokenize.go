@@ -0,0 +1,46 @@
+package retrieval
+
+import "unicode"
+
+// stopwords are too common to tell passages apart.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "with": true,
+}
+
+// tokenize lowercases text and splits it into terms. Identifiers are split
+// at underscores and case changes as well, so "refreshToken" and
+// "refresh_token" both match a query for "refresh token".
+func tokenize(text string) []string {
+	var terms []string
+	var cur []rune
+	emit := func() {
+		if len(cur) > 1 {
+			t := string(cur)
+			if !stopwords[t] {
+				terms = append(terms, t)
+			}
+		}
+		cur = cur[:0]
+	}
+
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				emit()
+			}
+			cur = append(cur, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			cur = append(cur, r)
+		default:
+			emit()
+		}
+		prev = r
+	}
+	emit()
+	return terms
+}
@@ -0,0 +1,30 @@
+package retrieval
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Refresh the token", []string{"refresh", "token"}},
+		{"refreshToken", []string{"refresh", "token"}},
+		{"refresh_token", []string{"refresh", "token"}},
+		{"REFRESH-TOKEN", []string{"refresh", "token"}},
+		{"HTTPServer", []string{"httpserver"}}, // runs of capitals stay together
+		{"OAuth2Flow", []string{"oauth2", "flow"}},
+		{"retry after 30s, max 5", []string{"retry", "after", "30s", "max"}}, // single characters are dropped
+		{"It is a JWT for the API", []string{"jwt", "api"}},
+		{"Überweisung für café", []string{"überweisung", "für", "café"}},
+		{"/v1/token?grant_type=refresh", []string{"v1", "token", "grant", "type", "refresh"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}